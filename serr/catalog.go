@@ -0,0 +1,251 @@
+package serr
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+var (
+	DefaultCatalog = NewMessageCatalog("en")
+)
+
+type msgCoder interface {
+	GetMsgCode() string
+}
+
+// MessageCatalog 按 错误码+语言 保存的用户消息，消息内容为 text/template 模板，参数来自错误的 Fields
+type MessageCatalog struct {
+	DefaultLocale string
+	lock          sync.RWMutex
+	messages      map[string]map[string]*template.Template
+}
+
+func NewMessageCatalog(defaultLocale string) *MessageCatalog {
+	return &MessageCatalog{
+		DefaultLocale: defaultLocale,
+		messages:      make(map[string]map[string]*template.Template),
+	}
+}
+
+// Add 添加一条消息，例如 Add("zh-CN", "E_ORDER_NOT_FOUND", "订单 {{.orderID}} 不存在")，
+// 渲染时缺少参数视为失败，不会输出 "<no value>"
+func (x *MessageCatalog) Add(locale, code, message string) error {
+	tmpl, err := template.New(code).Option("missingkey=error").Parse(message)
+	if err != nil {
+		return WithStack(err)
+	}
+
+	locale = normalizeLocale(locale)
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	m, ok := x.messages[locale]
+	if !ok {
+		m = make(map[string]*template.Template)
+		x.messages[locale] = m
+	}
+	m[code] = tmpl
+	return nil
+}
+
+// LoadJSON 加载一个语言的消息，格式为 {"错误码": "消息模板"}
+func (x *MessageCatalog) LoadJSON(locale string, data []byte) error {
+	var messages map[string]string
+	err := json.Unmarshal(data, &messages)
+	if err != nil {
+		return Wrapf(err, "invalid message catalog for locale '%s'", locale)
+	}
+
+	for code, message := range messages {
+		err = x.Add(locale, code, message)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadFile 加载消息文件，文件名即语言，例如 locales/zh-CN.json
+func (x *MessageCatalog) LoadFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return WithStack(err)
+	}
+	return x.LoadJSON(localeFromFile(filepath.Base(file)), data)
+}
+
+// LoadFS 加载 fsys 中 dir 目录下所有的 .json 消息文件，可用于 embed.FS
+func (x *MessageCatalog) LoadFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return WithStack(err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return WithStack(err)
+		}
+		err = x.LoadJSON(localeFromFile(entry.Name()), data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Message 渲染错误码对应的消息，找不到时依次尝试上级语言（zh-CN -> zh）、默认语言，都没有时返回错误码本身
+func (x *MessageCatalog) Message(locale, code string, params map[string]interface{}) string {
+	return x.execute(x.find(code, locale), code, params)
+}
+
+// execute 渲染失败（例如缺少参数）时改用默认语言的消息，仍然失败时返回错误码本身
+func (x *MessageCatalog) execute(tmpl *template.Template, code string, params map[string]interface{}) string {
+	if r, ok := execute(tmpl, params); ok {
+		return r
+	}
+	if fallback := x.findExact(code, x.DefaultLocale); fallback != tmpl {
+		if r, ok := execute(fallback, params); ok {
+			return r
+		}
+	}
+	return code
+}
+
+func execute(tmpl *template.Template, params map[string]interface{}) (string, bool) {
+	if tmpl == nil {
+		return "", false
+	}
+
+	var sb strings.Builder
+	err := tmpl.Execute(&sb, params)
+	if err != nil {
+		return "", false
+	}
+	return sb.String(), true
+}
+
+// Localize 按 Accept-Language 渲染错误的用户消息，没有错误码时返回 err.Error()
+func (x *MessageCatalog) Localize(err error, acceptLanguage string) string {
+	if err == nil {
+		return ""
+	}
+
+	code := GetCode(err)
+	if code == "" {
+		return err.Error()
+	}
+	return x.localize(code, acceptLanguage, GetFields(err))
+}
+
+// LocalizeMsgCode 渲染 sproto.MsgCodeResult 等带 MsgCode 的结果
+func (x *MessageCatalog) LocalizeMsgCode(result msgCoder, acceptLanguage string) string {
+	code := result.GetMsgCode()
+	if code == "" {
+		return ""
+	}
+	return x.localize(code, acceptLanguage, nil)
+}
+
+func (x *MessageCatalog) localize(code, acceptLanguage string, params map[string]interface{}) string {
+	for _, locale := range ParseAcceptLanguage(acceptLanguage) {
+		if tmpl := x.findExact(code, locale); tmpl != nil {
+			return x.execute(tmpl, code, params)
+		}
+	}
+	return x.Message(x.DefaultLocale, code, params)
+}
+
+func (x *MessageCatalog) find(code, locale string) *template.Template {
+	locale = normalizeLocale(locale)
+	for locale != "" {
+		if tmpl := x.findExact(code, locale); tmpl != nil {
+			return tmpl
+		}
+		i := strings.LastIndex(locale, "-")
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+	return x.findExact(code, normalizeLocale(x.DefaultLocale))
+}
+
+func (x *MessageCatalog) findExact(code, locale string) *template.Template {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	if m, ok := x.messages[normalizeLocale(locale)]; ok {
+		return m[code]
+	}
+	return nil
+}
+
+// ParseAcceptLanguage 解析 Accept-Language 头，按权重从高到低返回语言，并在其后补充上级语言
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+
+	var items []weighted
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		q := 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			params := part[i+1:]
+			part = strings.TrimSpace(part[:i])
+			if j := strings.Index(params, "q="); j >= 0 {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(params[j+2:]), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if part == "*" || q <= 0 {
+			continue
+		}
+		items = append(items, weighted{locale: normalizeLocale(part), q: q})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].q > items[j].q
+	})
+
+	seen := make(map[string]bool)
+	var r []string
+	add := func(locale string) {
+		if !seen[locale] {
+			seen[locale] = true
+			r = append(r, locale)
+		}
+	}
+	for _, item := range items {
+		add(item.locale)
+	}
+	for _, item := range items {
+		if i := strings.Index(item.locale, "-"); i > 0 {
+			add(item.locale[:i])
+		}
+	}
+	return r
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+func localeFromFile(name string) string {
+	return strings.TrimSuffix(name, path.Ext(name))
+}
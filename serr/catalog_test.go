@@ -0,0 +1,38 @@
+package serr
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/syncfuture/go/sproto"
+)
+
+func TestMessageCatalog(t *testing.T) {
+	catalog := NewMessageCatalog("en")
+	err := catalog.LoadFS(os.DirFS("testdata"), "locales")
+	assert.NoError(t, err)
+
+	err = WithFields(NewCode("E_ORDER_NOT_FOUND", "order not found"), map[string]interface{}{"orderID": "1001"})
+	assert.Equal(t, "订单 1001 不存在", catalog.Localize(err, "zh-CN,zh;q=0.9,en;q=0.8"))
+	assert.Equal(t, "订单 1001 不存在", catalog.Localize(err, "zh-cn"))
+	assert.Equal(t, "Order 1001 was not found", catalog.Localize(err, "fr-FR,fr;q=0.9"))
+	assert.Equal(t, "Order 1001 was not found", catalog.Localize(err, ""))
+	assert.Equal(t, "plain", catalog.Localize(New("plain"), "zh-CN"))
+
+	result := &sproto.MsgCodeResult{MsgCode: "E_UNAUTHORIZED"}
+	assert.Equal(t, "Please sign in first", catalog.LocalizeMsgCode(result, "zh-CN"))
+	assert.Equal(t, "E_UNKNOWN", catalog.Message("en", "E_UNKNOWN", nil))
+
+	// 缺少参数时改用默认语言，默认语言也缺少参数时返回错误码
+	catalog.Add("zh-CN", "E_MISSING", "缺少 {{.name}}")
+	catalog.Add("en", "E_MISSING", "Missing value")
+	assert.Equal(t, "Missing value", catalog.Message("zh-CN", "E_MISSING", nil))
+	assert.Equal(t, "缺少 id", catalog.Message("zh-CN", "E_MISSING", map[string]interface{}{"name": "id"}))
+	assert.Equal(t, "E_ORDER_NOT_FOUND", catalog.Localize(NewCode("E_ORDER_NOT_FOUND", "order not found"), "zh-CN"))
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	r := ParseAcceptLanguage("en;q=0.5, zh-CN, fr;q=0.8, *;q=0.1")
+	assert.Equal(t, []string{"zh-cn", "fr", "en", "zh"}, r)
+}
@@ -0,0 +1,119 @@
+package serr
+
+import (
+	"fmt"
+	"io"
+)
+
+type coder interface {
+	Code() string
+}
+
+type fielder interface {
+	Fields() map[string]interface{}
+}
+
+// NewCode 创建带错误码的错误
+func NewCode(code, msg string) error {
+	return &codedError{cause: New(msg), code: code}
+}
+
+// WithCode 给错误附加错误码，错误码用于前端提示、多语言消息等
+func WithCode(err error, code string) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{cause: err, code: code}
+}
+
+// WithFields 给错误附加结构化字段，序列化时输出
+func WithFields(err error, fields map[string]interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return &fieldsError{cause: err, fields: fields}
+}
+
+// GetCode 返回错误链上最外层的错误码
+func GetCode(err error) string {
+	var c coder
+	if As(err, &c) {
+		return c.Code()
+	}
+	return ""
+}
+
+// GetFields 合并错误链上所有的字段，外层的同名字段覆盖内层
+func GetFields(err error) map[string]interface{} {
+	var layers []map[string]interface{}
+	for e := err; e != nil; e = unwrap(e) {
+		if f, ok := e.(fielder); ok {
+			layers = append(layers, f.Fields())
+		}
+	}
+	if len(layers) == 0 {
+		return nil
+	}
+
+	r := make(map[string]interface{})
+	for i := len(layers) - 1; i >= 0; i-- {
+		for k, v := range layers[i] {
+			r[k] = v
+		}
+	}
+	return r
+}
+
+func unwrap(err error) error {
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		return e.Unwrap()
+	case interface{ Cause() error }:
+		return e.Cause()
+	}
+	return nil
+}
+
+type codedError struct {
+	cause error
+	code  string
+}
+
+func (x *codedError) Error() string { return x.cause.Error() }
+func (x *codedError) Cause() error  { return x.cause }
+func (x *codedError) Unwrap() error { return x.cause }
+func (x *codedError) Code() string  { return x.code }
+
+func (x *codedError) Format(s fmt.State, verb rune) {
+	formatWrapper(s, verb, x, x.cause)
+}
+
+type fieldsError struct {
+	cause  error
+	fields map[string]interface{}
+}
+
+func (x *fieldsError) Error() string                  { return x.cause.Error() }
+func (x *fieldsError) Cause() error                   { return x.cause }
+func (x *fieldsError) Unwrap() error                  { return x.cause }
+func (x *fieldsError) Fields() map[string]interface{} { return x.fields }
+
+func (x *fieldsError) Format(s fmt.State, verb rune) {
+	formatWrapper(s, verb, x, x.cause)
+}
+
+// formatWrapper 不改变消息的包装错误使用，%+v 输出内部错误的详细信息
+func formatWrapper(s fmt.State, verb rune, err, cause error) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			fmt.Fprintf(s, "%+v", cause)
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, err.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", err.Error())
+	}
+}
@@ -0,0 +1,235 @@
+package serr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+var (
+	DefaultJSONOptions = &JSONOptions{
+		TrimRuntime: true,
+		TrimTesting: true,
+		MaxFrames:   32,
+	}
+
+	_mainModule = func() string {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			return info.Main.Path
+		}
+		return ""
+	}()
+)
+
+// JSONOptions 错误序列化选项
+type JSONOptions struct {
+	// TrimRuntime 去掉 runtime 包的栈帧
+	TrimRuntime bool
+	// TrimTesting 去掉 testing 包的栈帧
+	TrimTesting bool
+	// Module 栈帧路径中去掉的模块前缀，为空时使用主模块
+	Module string
+	// MaxFrames 最多输出的栈帧数，0 表示不限
+	MaxFrames int
+}
+
+// ErrorDTO 错误的可序列化表示，Messages 从外到内依次为每层包装附加的消息。
+// Retryable 为 nil 表示发送方无法判断是否可以重试
+type ErrorDTO struct {
+	Message   string                 `json:"message"`
+	Messages  []string               `json:"messages,omitempty"`
+	Code      string                 `json:"code,omitempty"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+	Retryable *bool                  `json:"retryable,omitempty"`
+	Stack     []*FrameDTO            `json:"stack,omitempty"`
+}
+
+type FrameDTO struct {
+	Func string `json:"func"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// NewErrorDTO 把错误链转换为 ErrorDTO，options 为 nil 时使用 DefaultJSONOptions
+func NewErrorDTO(err error, options *JSONOptions) *ErrorDTO {
+	if err == nil {
+		return nil
+	}
+	if options == nil {
+		options = DefaultJSONOptions
+	}
+
+	if r, ok := err.(*RemoteError); ok {
+		dto := r.ErrorDTO
+		return &dto
+	}
+
+	r := &ErrorDTO{
+		Message: err.Error(),
+		Code:    GetCode(err),
+		Fields:  GetFields(err),
+	}
+	// 只有明确标记为 Permanent 的错误才告诉接收方不可重试，其他不可重试的错误可能只是无法判断
+	if IsPermanent(err) {
+		retryable := false
+		r.Retryable = &retryable
+	} else if IsRetryable(err) {
+		retryable := true
+		r.Retryable = &retryable
+	}
+
+	var pcs []uintptr
+	for e := err; e != nil; e = unwrap(e) {
+		msg := e.Error()
+		cause := unwrap(e)
+		if cause != nil {
+			causeMsg := cause.Error()
+			if msg == causeMsg {
+				msg = ""
+			} else {
+				msg = strings.TrimSuffix(msg, ": "+causeMsg)
+			}
+		}
+		if msg != "" {
+			r.Messages = append(r.Messages, msg)
+		}
+
+		// 使用最内层的调用栈，它离错误发生的位置最近
+		if st, ok := e.(stackTracer); ok {
+			trace := st.StackTrace()
+			pcs = make([]uintptr, len(trace))
+			for i, f := range trace {
+				pcs[i] = uintptr(f)
+			}
+		}
+	}
+	r.Stack = buildFrames(pcs, options)
+
+	return r
+}
+
+// MarshalJSON 把错误链序列化为 JSON
+func MarshalJSON(err error, options *JSONOptions) ([]byte, error) {
+	return json.Marshal(NewErrorDTO(err, options))
+}
+
+// UnmarshalJSON 把 MarshalJSON 生成的 JSON 还原为 *RemoteError
+func UnmarshalJSON(data []byte) (*RemoteError, error) {
+	r := new(RemoteError)
+	err := json.Unmarshal(data, &r.ErrorDTO)
+	if err != nil {
+		return nil, WithStack(err)
+	}
+	return r, nil
+}
+
+// RemoteError 从 JSON 还原的错误，可以通过 GetCode、GetFields、IsRetryable 读取原错误的信息
+type RemoteError struct {
+	ErrorDTO
+}
+
+func (x *RemoteError) Error() string                  { return x.Message }
+func (x *RemoteError) Code() string                   { return x.ErrorDTO.Code }
+func (x *RemoteError) Fields() map[string]interface{} { return x.ErrorDTO.Fields }
+func (x *RemoteError) Retryable() bool {
+	return x.ErrorDTO.Retryable != nil && *x.ErrorDTO.Retryable
+}
+
+// RetryableKnown 发送方是否说明了错误可以或不可以重试，为 false 时 IsRetryable 和 IsPermanent 都返回 false
+func (x *RemoteError) RetryableKnown() bool { return x.ErrorDTO.Retryable != nil }
+
+func (x *RemoteError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			io.WriteString(s, x.Message)
+			for _, f := range x.Stack {
+				fmt.Fprintf(s, "\n%s\n\t%s:%d", f.Func, f.File, f.Line)
+			}
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, x.Message)
+	case 'q':
+		fmt.Fprintf(s, "%q", x.Message)
+	}
+}
+
+func buildFrames(pcs []uintptr, options *JSONOptions) []*FrameDTO {
+	if len(pcs) == 0 {
+		return nil
+	}
+
+	module := options.Module
+	if module == "" {
+		module = _mainModule
+	}
+
+	var r []*FrameDTO
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" && !skipFrame(frame.Function, options) {
+			r = append(r, &FrameDTO{
+				Func: trimModule(frame.Function, module),
+				File: trimModule(frameFile(frame), module),
+				Line: frame.Line,
+			})
+			if options.MaxFrames > 0 && len(r) >= options.MaxFrames {
+				break
+			}
+		}
+		if !more {
+			break
+		}
+	}
+	return r
+}
+
+func skipFrame(function string, options *JSONOptions) bool {
+	pkg := funcPackage(function)
+	if options.TrimRuntime && (pkg == "runtime" || strings.HasPrefix(pkg, "runtime/")) {
+		return true
+	}
+	if options.TrimTesting && pkg == "testing" {
+		return true
+	}
+	return false
+}
+
+// frameFile 用包路径替换 GOPATH、模块缓存等绝对路径，例如 github.com/syncfuture/go/serr/serr.go
+func frameFile(frame runtime.Frame) string {
+	pkg := funcPackage(frame.Function)
+	if pkg == "" {
+		return path.Base(frame.File)
+	}
+	return pkg + "/" + path.Base(frame.File)
+}
+
+// funcPackage 从 github.com/syncfuture/go/serr.(*PanicError).Error 中取得 github.com/syncfuture/go/serr
+func funcPackage(function string) string {
+	lastSlash := strings.LastIndex(function, "/")
+	if lastSlash < 0 {
+		lastSlash = 0
+	}
+	dot := strings.Index(function[lastSlash:], ".")
+	if dot < 0 {
+		return ""
+	}
+	return function[:lastSlash+dot]
+}
+
+func trimModule(s, module string) string {
+	if module == "" || module == "command-line-arguments" {
+		return s
+	}
+	if strings.HasPrefix(s, module+"/") {
+		return s[len(module)+1:]
+	}
+	return s
+}
@@ -0,0 +1,53 @@
+package serr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	err := WithFields(WithCode(Wrap(test1(), "load order"), "E_ORDER"), map[string]interface{}{"orderID": "1001"})
+
+	data, err := MarshalJSON(err, nil)
+	assert.NoError(t, err)
+	t.Log(string(data))
+
+	r, err := UnmarshalJSON(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "load order: test3", r.Error())
+	assert.Equal(t, []string{"load order", "test3"}, r.Messages)
+	assert.Equal(t, "E_ORDER", GetCode(r))
+	assert.Equal(t, "1001", GetFields(r)["orderID"])
+	assert.NotEmpty(t, r.Stack)
+	for _, f := range r.Stack {
+		assert.False(t, strings.HasPrefix(f.File, "/"), f.File)
+		assert.False(t, strings.HasPrefix(f.Func, "testing."), f.Func)
+		assert.False(t, strings.HasPrefix(f.Func, "runtime."), f.Func)
+	}
+	assert.Equal(t, "test3", r.Stack[0].Func[strings.LastIndex(r.Stack[0].Func, ".")+1:])
+
+	assert.Nil(t, r.ErrorDTO.Retryable)
+	assert.False(t, IsRetryable(r))
+	assert.False(t, IsPermanent(r))
+
+	for _, c := range []struct {
+		err       error
+		retryable bool
+		permanent bool
+	}{
+		{Retryable(New("busy")), true, false},
+		{Permanent(New("invalid")), false, true},
+	} {
+		data, _ = MarshalJSON(c.err, nil)
+		r, err = UnmarshalJSON(data)
+		assert.NoError(t, err)
+		assert.Equal(t, c.retryable, IsRetryable(Wrap(r, "remote")))
+		assert.Equal(t, c.permanent, IsPermanent(Wrap(r, "remote")))
+	}
+
+	data, err = MarshalJSON(test1(), &JSONOptions{})
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "testing.tRunner")
+}
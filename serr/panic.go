@@ -0,0 +1,128 @@
+package serr
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+
+	log "github.com/kataras/golog"
+	"github.com/pkg/errors"
+)
+
+var (
+	_panicHandlerLock sync.RWMutex
+	_panicHandler     = func(err error) {
+		// 没有引用 slog 的程序直接用 golog 输出，引用了 slog 时 slog 的 init 会替换为 slog.Errorf
+		log.Errorf("%+v", err)
+	}
+)
+
+// PanicError 由 panic 恢复得到的错误，保留 panic 的值和发生时的调用栈
+type PanicError struct {
+	Value interface{}
+	stack []uintptr
+}
+
+func newPanicError(value interface{}, skip int) *PanicError {
+	const depth = 64
+	var pcs [depth]uintptr
+	n := runtime.Callers(skip, pcs[:])
+
+	// 去掉开头 runtime.gopanic 等运行时栈帧，使第一帧就是发生 panic 的位置
+	frames := pcs[:n]
+	for len(frames) > 0 {
+		fn := runtime.FuncForPC(frames[0] - 1)
+		if fn == nil || !strings.HasPrefix(fn.Name(), "runtime.") {
+			break
+		}
+		frames = frames[1:]
+	}
+
+	return &PanicError{
+		Value: value,
+		stack: frames,
+	}
+}
+
+func (x *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", x.Value)
+}
+
+// Unwrap 当 panic 的值本身是 error 时返回该 error，便于 Is/As 判断
+func (x *PanicError) Unwrap() error {
+	if err, ok := x.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// StackTrace 实现 pkg/errors 的 stackTracer，WithStack 不会再重复包装
+func (x *PanicError) StackTrace() errors.StackTrace {
+	r := make(errors.StackTrace, len(x.stack))
+	for i, pc := range x.stack {
+		r[i] = errors.Frame(pc)
+	}
+	return r
+}
+
+func (x *PanicError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			io.WriteString(s, x.Error())
+			x.StackTrace().Format(s, verb)
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, x.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", x.Error())
+	}
+}
+
+// Recover 必须以 defer serr.Recover(&err) 的方式调用，把 panic 转换为 *PanicError 写入 errPtr
+func Recover(errPtr *error) {
+	if r := recover(); r != nil {
+		err := newPanicError(r, 3)
+		if errPtr != nil {
+			*errPtr = err
+		} else {
+			handlePanic(err)
+		}
+	}
+}
+
+// Try 执行 fn，fn 中发生的 panic 会被转换为 *PanicError 返回
+func Try(fn func() error) (err error) {
+	defer Recover(&err)
+	return fn()
+}
+
+// Go 在新的 goroutine 中执行 fn，fn 返回的错误和恢复的 panic 都交给 panic handler 处理
+func Go(fn func() error) {
+	go func() {
+		if err := Try(fn); err != nil {
+			handlePanic(err)
+		}
+	}()
+}
+
+// SetPanicHandler 设置 Go 和 Recover(nil) 的错误处理函数，默认用 golog 输出，引用了 slog 时为 slog.Errorf
+func SetPanicHandler(handler func(error)) {
+	if handler == nil {
+		return
+	}
+	_panicHandlerLock.Lock()
+	_panicHandler = handler
+	_panicHandlerLock.Unlock()
+}
+
+func handlePanic(err error) {
+	_panicHandlerLock.RLock()
+	handler := _panicHandler
+	_panicHandlerLock.RUnlock()
+	handler(err)
+}
@@ -0,0 +1,271 @@
+package serr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	DefaultRetryPolicy = &RetryPolicy{
+		MaxAttempts:     3,
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     10 * time.Second,
+		Multiplier:      2,
+		Jitter:          0.2,
+		MaxElapsedTime:  30 * time.Second,
+	}
+
+	_classifiersLock sync.RWMutex
+	_classifiers     []func(error) (retryable bool, ok bool)
+)
+
+type retryable interface {
+	Retryable() bool
+}
+
+// retryableKnown 实现了 retryable 但可能无法判断的错误，例如 RemoteError
+type retryableKnown interface {
+	RetryableKnown() bool
+}
+
+type retryAfter interface {
+	RetryAfter() time.Duration
+}
+
+// RetryPolicy 重试策略，零值字段使用 DefaultRetryPolicy 中对应的值
+type RetryPolicy struct {
+	// MaxAttempts 最多执行次数（包含第一次），小于 0 表示不限次数
+	MaxAttempts     int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	// Jitter 随机抖动比例，0.2 表示在计算出的等待时间上下浮动 20%，小于 0 表示不抖动
+	Jitter float64
+	// MaxElapsedTime 从第一次执行开始允许的最长总时间，小于 0 表示不限
+	MaxElapsedTime time.Duration
+	// RetryIf 判断错误是否需要重试，默认 IsRetryable
+	RetryIf func(error) bool
+	// OnAttempt 每次执行完成后调用，err 可能为 nil
+	OnAttempt func(attempt int, err error)
+	// OnRetry 确定要重试、开始等待前调用
+	OnRetry func(attempt int, err error, wait time.Duration)
+}
+
+// Retryable 标记错误可以重试
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{cause: err, retryable: true}
+}
+
+// Permanent 标记错误不可重试，即使内部的错误本身是可重试的
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{cause: err, retryable: false}
+}
+
+// RetryAfter 标记错误可以在 d 之后重试
+func RetryAfter(err error, d time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{cause: err, retryable: true, retryAfter: d}
+}
+
+// RegisterClassifier 注册额外的错误分类函数，ok 为 false 表示该函数无法判断，例如 sredis 注册 go-redis 的错误
+func RegisterClassifier(classifier func(error) (retryable bool, ok bool)) {
+	_classifiersLock.Lock()
+	_classifiers = append(_classifiers, classifier)
+	_classifiersLock.Unlock()
+}
+
+// IsPermanent 错误链上最外层的标记为 Permanent
+func IsPermanent(err error) bool {
+	r, ok := classified(err)
+	return ok && !r
+}
+
+// IsRetryable 判断错误是否可以重试，依次检查 Retryable/Permanent 标记、context、注册的分类函数和常见的网络错误
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if r, ok := classified(err); ok {
+		return r
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		// 单次调用的超时可以重试，外层 context 过期时 Retry 会自行停止
+		return true
+	}
+
+	_classifiersLock.RLock()
+	classifiers := _classifiers
+	_classifiersLock.RUnlock()
+	for _, classifier := range classifiers {
+		if r, ok := classifier(err); ok {
+			return r
+		}
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return netErr.Timeout() || netErr.Temporary()
+	}
+
+	return false
+}
+
+// classified 返回错误链上最外层的 Retryable/Permanent 标记，ok 为 false 表示没有标记，
+// 无法判断的错误（例如发送方没有说明的 RemoteError）跳过，继续检查内层的错误
+func classified(err error) (r bool, ok bool) {
+	for e := err; e != nil; e = unwrap(e) {
+		c, is := e.(retryable)
+		if !is {
+			continue
+		}
+		if k, is := e.(retryableKnown); is && !k.RetryableKnown() {
+			continue
+		}
+		return c.Retryable(), true
+	}
+	return false, false
+}
+
+// GetRetryAfter 返回错误链上指定的重试等待时间
+func GetRetryAfter(err error) (time.Duration, bool) {
+	var r retryAfter
+	if errors.As(err, &r) {
+		if d := r.RetryAfter(); d > 0 {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// Retry 按照 policy 执行 fn，直到成功、遇到不可重试的错误、次数或时间用尽、或 ctx 结束
+func Retry(ctx context.Context, policy *RetryPolicy, fn func(ctx context.Context) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	p := policy.withDefaults()
+
+	start := time.Now()
+	interval := p.InitialInterval
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if p.OnAttempt != nil {
+			p.OnAttempt(attempt, err)
+		}
+		if err == nil {
+			return nil
+		}
+		if !p.RetryIf(err) {
+			return err
+		}
+		if p.MaxAttempts >= 0 && attempt >= p.MaxAttempts {
+			return err
+		}
+
+		// 使用服务器指定的等待时间时退避间隔不增长，之后没有指定时仍从当前间隔继续
+		wait, ok := GetRetryAfter(err)
+		if !ok {
+			wait = jitter(interval, p.Jitter)
+			interval = time.Duration(math.Min(float64(interval)*p.Multiplier, float64(p.MaxInterval)))
+		}
+
+		if p.MaxElapsedTime >= 0 && time.Since(start)+wait > p.MaxElapsedTime {
+			return err
+		}
+
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, err, wait)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return Wrapf(ctx.Err(), "retry aborted after %d attempts, last error: %v", attempt, err)
+		case <-timer.C:
+		}
+	}
+}
+
+func (x *RetryPolicy) withDefaults() RetryPolicy {
+	var r RetryPolicy
+	if x != nil {
+		r = *x
+	}
+	d := DefaultRetryPolicy
+	if r.MaxAttempts == 0 {
+		r.MaxAttempts = d.MaxAttempts
+	}
+	if r.InitialInterval <= 0 {
+		r.InitialInterval = d.InitialInterval
+	}
+	if r.MaxInterval <= 0 {
+		r.MaxInterval = d.MaxInterval
+	}
+	if r.Multiplier < 1 {
+		r.Multiplier = d.Multiplier
+	}
+	if r.Jitter == 0 {
+		r.Jitter = d.Jitter
+	}
+	if r.MaxElapsedTime == 0 {
+		r.MaxElapsedTime = d.MaxElapsedTime
+	}
+	if r.RetryIf == nil {
+		r.RetryIf = IsRetryable
+	}
+	return r
+}
+
+func jitter(d time.Duration, factor float64) time.Duration {
+	if factor <= 0 {
+		return d
+	}
+	delta := factor * float64(d)
+	min := float64(d) - delta
+	return time.Duration(min + rand.Float64()*(2*delta))
+}
+
+type classifiedError struct {
+	cause      error
+	retryable  bool
+	retryAfter time.Duration
+}
+
+func (x *classifiedError) Error() string { return x.cause.Error() }
+func (x *classifiedError) Cause() error  { return x.cause }
+func (x *classifiedError) Unwrap() error { return x.cause }
+
+func (x *classifiedError) Retryable() bool           { return x.retryable }
+func (x *classifiedError) RetryAfter() time.Duration { return x.retryAfter }
+
+func (x *classifiedError) Format(s fmt.State, verb rune) {
+	formatWrapper(s, verb, x, x.cause)
+}
@@ -0,0 +1,118 @@
+package serr
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetryable(t *testing.T) {
+	assert.False(t, IsRetryable(nil))
+	assert.False(t, IsRetryable(New("plain")))
+	assert.True(t, IsRetryable(Retryable(New("plain"))))
+	assert.True(t, IsRetryable(Wrap(Retryable(New("plain")), "wrapped")))
+	assert.False(t, IsRetryable(Permanent(Retryable(New("plain")))))
+	assert.True(t, IsPermanent(Wrap(Permanent(New("plain")), "wrapped")))
+
+	assert.True(t, IsRetryable(Wrap(io.ErrUnexpectedEOF, "read")))
+	assert.False(t, IsRetryable(io.EOF))
+	assert.False(t, IsRetryable(context.Canceled))
+	assert.True(t, IsRetryable(&net.DNSError{IsTimeout: true}))
+
+	// 无法判断的错误由内层的错误决定
+	assert.True(t, IsPermanent(&unknownRetryable{cause: Permanent(New("invalid"))}))
+	assert.True(t, IsRetryable(&unknownRetryable{cause: Wrap(io.ErrUnexpectedEOF, "read")}))
+
+	d, ok := GetRetryAfter(Wrap(RetryAfter(New("busy"), 3*time.Second), "wrapped"))
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, d)
+}
+
+func TestRetry(t *testing.T) {
+	policy := &RetryPolicy{
+		MaxAttempts:     5,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     5 * time.Millisecond,
+	}
+
+	var retries int
+	policy.OnRetry = func(attempt int, err error, wait time.Duration) {
+		retries++
+	}
+
+	attempts := 0
+	err := Retry(context.Background(), policy, func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return Retryable(New("transient"))
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, 2, retries)
+
+	attempts = 0
+	err = Retry(context.Background(), policy, func(ctx context.Context) error {
+		attempts++
+		return New("fatal")
+	})
+	assert.EqualError(t, err, "fatal")
+	assert.Equal(t, 1, attempts)
+
+	attempts = 0
+	err = Retry(context.Background(), policy, func(ctx context.Context) error {
+		attempts++
+		return Retryable(New("transient"))
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 5, attempts)
+
+	var waits []time.Duration
+	noJitter := &RetryPolicy{
+		MaxAttempts:     3,
+		InitialInterval: time.Millisecond,
+		Jitter:          -1,
+		OnRetry: func(attempt int, err error, wait time.Duration) {
+			waits = append(waits, wait)
+		},
+	}
+	Retry(context.Background(), noJitter, func(ctx context.Context) error {
+		return Retryable(New("transient"))
+	})
+	assert.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond}, waits)
+
+	// 服务器指定的等待时间不会让退避间隔增长
+	waits = nil
+	noJitter.MaxAttempts = 4
+	attempts = 0
+	Retry(context.Background(), noJitter, func(ctx context.Context) error {
+		attempts++
+		if attempts == 1 {
+			return RetryAfter(New("busy"), 3*time.Millisecond)
+		}
+		return Retryable(New("transient"))
+	})
+	assert.Equal(t, []time.Duration{3 * time.Millisecond, time.Millisecond, 2 * time.Millisecond}, waits)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = Retry(ctx, &RetryPolicy{InitialInterval: time.Minute, MaxInterval: time.Minute, MaxElapsedTime: -1}, func(ctx context.Context) error {
+		cancel()
+		return Retryable(New("transient"))
+	})
+	assert.True(t, Is(err, context.Canceled))
+}
+
+// unknownRetryable 实现了 retryable 但无法判断是否可以重试的包装
+type unknownRetryable struct {
+	cause error
+}
+
+func (x *unknownRetryable) Error() string        { return x.cause.Error() }
+func (x *unknownRetryable) Unwrap() error        { return x.cause }
+func (x *unknownRetryable) Retryable() bool      { return false }
+func (x *unknownRetryable) RetryableKnown() bool { return false }
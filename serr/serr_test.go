@@ -3,8 +3,10 @@ package serr
 import (
 	"log"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 )
 
 func Test(t *testing.T) {
@@ -26,3 +28,51 @@ func test2() error {
 func test3() error {
 	return errors.New("test3")
 }
+
+func TestTry(t *testing.T) {
+	err := Try(func() error {
+		var m map[string]int
+		m["a"] = 1
+		return nil
+	})
+	assert.Error(t, err)
+
+	var pe *PanicError
+	assert.True(t, As(err, &pe))
+	assert.NotEmpty(t, pe.StackTrace())
+	log.Printf("%+v", err)
+
+	target := New("target")
+	err = Try(func() error {
+		panic(target)
+	})
+	assert.True(t, Is(err, target))
+
+	err = Try(func() error {
+		return target
+	})
+	assert.Equal(t, target, err)
+}
+
+func TestGo(t *testing.T) {
+	_panicHandlerLock.RLock()
+	previous := _panicHandler
+	_panicHandlerLock.RUnlock()
+	t.Cleanup(func() { SetPanicHandler(previous) })
+
+	ch := make(chan error, 1)
+	SetPanicHandler(func(err error) {
+		ch <- err
+	})
+
+	Go(func() error {
+		panic("boom")
+	})
+
+	select {
+	case err := <-ch:
+		assert.EqualError(t, err, "panic: boom")
+	case <-time.After(time.Second):
+		t.Fatal("panic handler was not called")
+	}
+}
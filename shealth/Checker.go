@@ -0,0 +1,75 @@
+package shealth
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/syncfuture/go/serr"
+	"github.com/syncfuture/go/surl"
+)
+
+// RedisChecker 使用 PING 检查 Redis，client 可以是 sredis.NewClient 返回的单机或集群客户端
+func RedisChecker(client redis.Cmdable) IChecker {
+	return CheckerFunc(func(ctx context.Context) error {
+		return serr.WithStack(client.Ping(ctx).Err())
+	})
+}
+
+// HTTPChecker 使用 GET 检查下游的 HTTP 依赖，2xx 和 3xx 响应表示可用。
+// url 可以包含 {{URI 'key'}}，由 urlProvider 渲染，urlProvider 为 nil 时原样使用，client 为 nil 时使用 http.DefaultClient
+func HTTPChecker(client *http.Client, urlProvider surl.IURLProvider, url string) IChecker {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return CheckerFunc(func(ctx context.Context) error {
+		u := url
+		if urlProvider != nil {
+			u = urlProvider.RenderURLCache(u)
+		}
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return serr.WithStack(err)
+		}
+		resp, err := client.Do(request)
+		if err != nil {
+			return serr.WithStack(err)
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+		if resp.StatusCode >= http.StatusBadRequest {
+			return serr.Errorf("GET %s returned %s", u, resp.Status)
+		}
+		return nil
+	})
+}
+
+// DiskSpaceChecker 检查 path 所在磁盘的可用空间不少于 minFreeBytes
+func DiskSpaceChecker(path string, minFreeBytes uint64) IChecker {
+	return CheckerFunc(func(ctx context.Context) error {
+		free, err := diskFree(path)
+		if err != nil {
+			return err
+		}
+		if free < minFreeBytes {
+			return serr.Errorf("%s has %s free, less than %s", path, formatBytes(free), formatBytes(minFreeBytes))
+		}
+		return nil
+	})
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
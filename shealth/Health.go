@@ -0,0 +1,299 @@
+package shealth
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/syncfuture/go/serr"
+	"github.com/syncfuture/go/shttp"
+	log "github.com/syncfuture/go/slog"
+)
+
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+	// StatusWarn 可选检查失败，不影响整体状态
+	StatusWarn Status = "warn"
+)
+
+// IChecker 检查一个依赖是否可用，返回 nil 表示可用，应该在 ctx 结束时尽快返回
+type IChecker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc 把函数转换为 IChecker
+type CheckerFunc func(ctx context.Context) error
+
+func (x CheckerFunc) Check(ctx context.Context) error {
+	return x(ctx)
+}
+
+type CheckOptions struct {
+	// Timeout 检查的超时时间，默认 HealthConfig.Timeout
+	Timeout time.Duration
+	// CacheTTL 检查结果的缓存时间，默认 HealthConfig.CacheTTL，小于 0 表示不缓存
+	CacheTTL time.Duration
+	// Optional 为 true 时检查失败只报告 warn，不影响整体状态
+	Optional bool
+}
+
+type HealthConfig struct {
+	// Timeout 检查的默认超时时间，默认 3 秒
+	Timeout time.Duration
+	// CacheTTL 检查结果的默认缓存时间，默认 5 秒，小于 0 表示不缓存
+	CacheTTL time.Duration
+}
+
+// IHealth 注册健康检查并提供给 Kubernetes 等探测使用的存活和就绪接口
+type IHealth interface {
+	// AddLivenessCheck 注册存活检查，存活检查失败表示进程需要重启，不应该包含外部依赖
+	AddLivenessCheck(name string, checker IChecker, options *CheckOptions)
+	// AddReadinessCheck 注册就绪检查，就绪检查失败表示暂时不能接收请求，同时会执行存活检查
+	AddReadinessCheck(name string, checker IChecker, options *CheckOptions)
+	// SetReady 设置为 false 时就绪检查直接失败，用于关闭前让负载均衡先摘除实例
+	SetReady(ready bool)
+	Liveness(ctx context.Context) *Report
+	Readiness(ctx context.Context) *Report
+	// LivenessHandler 和 ReadinessHandler 以 JSON 返回 Report，状态为 down 时返回 503
+	LivenessHandler() http.Handler
+	ReadinessHandler() http.Handler
+}
+
+type Report struct {
+	Status Status                  `json:"status"`
+	Checks map[string]*CheckResult `json:"checks,omitempty"`
+	Time   time.Time               `json:"time"`
+}
+
+type CheckResult struct {
+	Status Status `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// LatencyMS 检查耗时的毫秒数，缓存的结果为执行时的耗时
+	LatencyMS float64   `json:"latencyMs"`
+	CheckedAt time.Time `json:"checkedAt"`
+	Cached    bool      `json:"cached,omitempty"`
+}
+
+type health struct {
+	config    HealthConfig
+	lock      sync.RWMutex
+	liveness  []*check
+	readiness []*check
+	notReady  bool
+}
+
+type check struct {
+	name    string
+	checker IChecker
+	options CheckOptions
+	lock    sync.Mutex
+	result  *CheckResult
+}
+
+func NewHealth(config *HealthConfig) IHealth {
+	r := new(health)
+	if config != nil {
+		r.config = *config
+	}
+	if r.config.Timeout <= 0 {
+		r.config.Timeout = 3 * time.Second
+	}
+	if r.config.CacheTTL == 0 {
+		r.config.CacheTTL = 5 * time.Second
+	}
+	return r
+}
+
+func (x *health) AddLivenessCheck(name string, checker IChecker, options *CheckOptions) {
+	c := x.newCheck(name, checker, options)
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.liveness = append(x.liveness, c)
+}
+
+func (x *health) AddReadinessCheck(name string, checker IChecker, options *CheckOptions) {
+	c := x.newCheck(name, checker, options)
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.readiness = append(x.readiness, c)
+}
+
+func (x *health) newCheck(name string, checker IChecker, options *CheckOptions) *check {
+	if name == "" {
+		log.Fatal("name cannot be empty")
+	}
+	if checker == nil {
+		log.Fatal("checker cannot be nil")
+	}
+
+	r := &check{
+		name:    name,
+		checker: checker,
+	}
+	if options != nil {
+		r.options = *options
+	}
+	if r.options.Timeout <= 0 {
+		r.options.Timeout = x.config.Timeout
+	}
+	if r.options.CacheTTL == 0 {
+		r.options.CacheTTL = x.config.CacheTTL
+	}
+	return r
+}
+
+func (x *health) SetReady(ready bool) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.notReady == !ready {
+		return
+	}
+	x.notReady = !ready
+	log.Infof("readiness set to %t", ready)
+}
+
+func (x *health) Liveness(ctx context.Context) *Report {
+	x.lock.RLock()
+	checks := x.liveness
+	x.lock.RUnlock()
+	return runChecks(ctx, checks)
+}
+
+func (x *health) Readiness(ctx context.Context) *Report {
+	x.lock.RLock()
+	notReady := x.notReady
+	checks := make([]*check, 0, len(x.liveness)+len(x.readiness))
+	checks = append(checks, x.liveness...)
+	checks = append(checks, x.readiness...)
+	x.lock.RUnlock()
+
+	if notReady {
+		return &Report{Status: StatusDown, Time: time.Now()}
+	}
+	return runChecks(ctx, checks)
+}
+
+func (x *health) LivenessHandler() http.Handler {
+	return reportHandler(x.Liveness)
+}
+
+func (x *health) ReadinessHandler() http.Handler {
+	return reportHandler(x.Readiness)
+}
+
+func reportHandler(run func(context.Context) *Report) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := run(r.Context())
+		statusCode := http.StatusOK
+		if report.Status == StatusDown {
+			statusCode = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		if r.Method == http.MethodHead {
+			w.WriteHeader(statusCode)
+			return
+		}
+		shttp.WriteJSON(w, statusCode, report)
+	})
+}
+
+// runChecks 并发执行检查，任何一个必需的检查失败时整体状态为 down
+func runChecks(ctx context.Context, checks []*check) *Report {
+	r := &Report{
+		Status: StatusUp,
+		Time:   time.Now(),
+	}
+	if len(checks) == 0 {
+		return r
+	}
+
+	results := make([]*CheckResult, len(checks))
+	wg := sync.WaitGroup{}
+	wg.Add(len(checks))
+	for i, c := range checks {
+		go func(i int, c *check) {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+
+	r.Checks = make(map[string]*CheckResult, len(checks))
+	for i, c := range checks {
+		r.Checks[c.name] = results[i]
+		if results[i].Status == StatusDown {
+			r.Status = StatusDown
+		}
+	}
+	return r
+}
+
+// run 返回缓存的结果，缓存过期时执行检查，同一个检查同时只执行一次
+func (x *check) run(ctx context.Context) *CheckResult {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	if x.result != nil && x.options.CacheTTL > 0 && time.Since(x.result.CheckedAt) < x.options.CacheTTL {
+		r := *x.result
+		r.Cached = true
+		return &r
+	}
+
+	start := time.Now()
+	err := x.execute(ctx)
+	r := &CheckResult{
+		Status:    StatusUp,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: start,
+	}
+	if err != nil {
+		r.Status = StatusDown
+		if x.options.Optional {
+			r.Status = StatusWarn
+		}
+		r.Error = err.Error()
+	}
+
+	if x.result == nil || x.result.Status != r.Status {
+		if err != nil {
+			log.Warnf("health check [%s] is %s: %v", x.name, r.Status, err)
+		} else if x.result != nil {
+			log.Infof("health check [%s] is %s", x.name, r.Status)
+		}
+	}
+	// 调用方取消时的结果不缓存
+	if ctx.Err() == nil || err == nil {
+		x.result = r
+	}
+	return r
+}
+
+// execute 在超时时间内执行检查，检查没有响应 ctx 时也会按时返回
+func (x *check) execute(ctx context.Context) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, x.options.Timeout)
+	defer cancel()
+
+	ch := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				ch <- serr.Errorf("health check panic: %v", r)
+			}
+		}()
+		ch <- x.checker.Check(ctx)
+	}()
+
+	select {
+	case err = <-ch:
+		return err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return serr.Errorf("timed out after %s", x.options.Timeout)
+		}
+		return serr.WithStack(ctx.Err())
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd && !windows
+// +build !linux,!darwin,!freebsd,!windows
+
+package shealth
+
+import (
+	"runtime"
+
+	"github.com/syncfuture/go/serr"
+)
+
+func diskFree(path string) (uint64, error) {
+	return 0, serr.Errorf("disk space check is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package shealth
+
+import (
+	"syscall"
+
+	"github.com/syncfuture/go/serr"
+)
+
+// diskFree 返回 path 所在文件系统中非特权用户可用的字节数
+func diskFree(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, serr.WithStack(err)
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
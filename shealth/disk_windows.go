@@ -0,0 +1,27 @@
+//go:build windows
+// +build windows
+
+package shealth
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/syncfuture/go/serr"
+)
+
+var _getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// diskFree 返回 path 所在磁盘中当前用户可用的字节数
+func diskFree(path string) (uint64, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, serr.WithStack(err)
+	}
+	var free uint64
+	r, _, err := _getDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&free)), 0, 0)
+	if r == 0 {
+		return 0, serr.WithStack(err)
+	}
+	return free, nil
+}
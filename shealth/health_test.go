@@ -0,0 +1,90 @@
+package shealth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth_Readiness(t *testing.T) {
+	var calls int32
+	var failing int32
+	h := NewHealth(&HealthConfig{Timeout: 50 * time.Millisecond, CacheTTL: time.Hour})
+	h.AddLivenessCheck("self", CheckerFunc(func(ctx context.Context) error { return nil }), nil)
+	h.AddReadinessCheck("db", CheckerFunc(func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&failing) == 1 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}), &CheckOptions{CacheTTL: -1})
+	h.AddReadinessCheck("cache", CheckerFunc(func(ctx context.Context) error {
+		return errors.New("unavailable")
+	}), &CheckOptions{Optional: true})
+	h.AddReadinessCheck("slow", CheckerFunc(func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}), &CheckOptions{Timeout: 10 * time.Millisecond, Optional: true})
+
+	start := time.Now()
+	report := h.Readiness(context.Background())
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+	assert.Equal(t, StatusUp, report.Status)
+	assert.Len(t, report.Checks, 4)
+	assert.Equal(t, StatusUp, report.Checks["db"].Status)
+	assert.Equal(t, StatusWarn, report.Checks["cache"].Status)
+	assert.Equal(t, "unavailable", report.Checks["cache"].Error)
+	assert.Contains(t, report.Checks["slow"].Error, "timed out")
+
+	// 必需的检查失败时返回 503，缓存的结果不会重新执行
+	atomic.StoreInt32(&failing, 1)
+	handler := h.ReadinessHandler()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	var got Report
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, StatusDown, got.Status)
+	assert.Equal(t, StatusDown, got.Checks["db"].Status)
+	assert.False(t, got.Checks["db"].Cached)
+	assert.True(t, got.Checks["cache"].Cached)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// 存活检查不包含就绪检查
+	w = httptest.NewRecorder()
+	h.LivenessHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// 关闭前设置为未就绪
+	atomic.StoreInt32(&failing, 0)
+	h.SetReady(false)
+	assert.Equal(t, StatusDown, h.Readiness(context.Background()).Status)
+	h.SetReady(true)
+	assert.Equal(t, StatusUp, h.Readiness(context.Background()).Status)
+}
+
+func TestCheckers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	assert.NoError(t, HTTPChecker(nil, nil, server.URL+"/health").Check(ctx))
+	assert.Error(t, HTTPChecker(nil, nil, server.URL+"/other").Check(ctx))
+
+	assert.NoError(t, DiskSpaceChecker(".", 1).Check(ctx))
+	err := DiskSpaceChecker(".", 1<<62).Check(ctx)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "free")
+}
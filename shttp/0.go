@@ -5,29 +5,34 @@ import (
 	"net/http"
 
 	"github.com/syncfuture/go/serr"
-	log "github.com/syncfuture/go/slog"
 	"github.com/syncfuture/go/spool"
-	"github.com/syncfuture/go/u"
 )
 
 const (
-	HEADER_CTYPE = "Content-Type"
-	HEADER_AUTH  = "Authorization"
-	CTYPE_TEXT   = "text/plain"
-	CTYPE_HTML   = "text/html"
-	CTYPE_XML    = "text/xml"
-	CTYPE_CSS    = "text/css"
-	CTYPE_JS     = "text/javascript"
-	CTYPE_JSON   = "application/json"
-	CTYPE_FORM   = "application/x-www-form-urlencoded"
-	CTYPE_MFORM  = "multipart/form-data"
-	CHARSET_UTF8 = "charset=utf-8"
+	HEADER_CTYPE            = "Content-Type"
+	HEADER_AUTH             = "Authorization"
+	HEADER_ACCEPT           = "Accept"
+	HEADER_RETRY_AFTER      = "Retry-After"
+	HEADER_CONTENT_ENCODING = "Content-Encoding"
+	HEADER_CONTENT_LENGTH   = "Content-Length"
+	CTYPE_TEXT              = "text/plain"
+	CTYPE_HTML              = "text/html"
+	CTYPE_XML               = "text/xml"
+	CTYPE_CSS               = "text/css"
+	CTYPE_JS                = "text/javascript"
+	CTYPE_JSON              = "application/json"
+	CTYPE_FORM              = "application/x-www-form-urlencoded"
+	CTYPE_MFORM             = "multipart/form-data"
+	CTYPE_OCTET             = "application/octet-stream"
+	CTYPE_PROTOBUF          = "application/x-protobuf"
+	CHARSET_UTF8            = "charset=utf-8"
 )
 
 var (
 	_bufferPool = spool.NewSyncBufferPool(1024)
 )
 
+// GetRespBuffer 读取响应体，非 2xx 的响应返回带有状态码和响应体的 *HTTPError
 func GetRespBuffer(resp *http.Response, err error) (*bytes.Buffer, error) {
 	if err != nil {
 		return nil, err
@@ -41,11 +46,14 @@ func GetRespBuffer(resp *http.Response, err error) (*bytes.Buffer, error) {
 		}
 	}()
 	if err != nil {
+		_bufferPool.PutBuffer(bf)
 		return nil, serr.WithStack(err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		log.Warnf("%s %s [%d] -> %s", resp.Request.Method, resp.Request.URL.String(), resp.StatusCode, u.BytesToStr(bf.Bytes()))
+	if !IsSuccessStatus(resp.StatusCode) {
+		httpErr := NewHTTPError(resp, bf.Bytes(), nil)
+		_bufferPool.PutBuffer(bf)
+		return nil, serr.WithStack(httpErr)
 	}
 
 	return bf, nil
@@ -2,21 +2,52 @@ package shttp
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
+	"io"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/syncfuture/go/serr"
 	"github.com/syncfuture/go/surl"
 )
 
 type APIClient struct {
 	URLProvider surl.IURLProvider
+	// Timeout 单次调用的默认超时时间（包含读取响应体），0 表示不限，可以用 WithTimeout 为单次调用覆盖
+	Timeout time.Duration
+	// ErrorDecoder 解析非 2xx 响应的 body，默认 DecodeMsgCodeResult
+	ErrorDecoder ErrorDecoder
+	// RetryPolicy 重试策略，nil 表示不重试，可以用 WithRetryPolicy 为单次调用覆盖
+	RetryPolicy *serr.RetryPolicy
+	// CanRetry 判断请求是否允许重试，默认 IsIdempotentRequest
+	CanRetry func(*http.Request) bool
+	// CircuitBreaker 熔断器，按 surl 的 URI key 区分，没有 URI key 时按主机名区分，nil 表示不使用
+	CircuitBreaker ICircuitBreaker
+	// Interceptors 每次发送请求（包括重试）都会经过的拦截器，可以用 WithInterceptors 为单次调用追加
+	Interceptors []Interceptor
+	// Cache 响应缓存，在拦截器和负载均衡之外，命中时不会经过拦截器、负载均衡和熔断器，nil 表示不缓存
+	Cache *ResponseCache
+	// LoadBalancer 把 URI key 的请求分发到多个端点，端点通过 SetEndpoints 设置，
+	// 或者由 URLProvider 返回以逗号分隔的多个 base URL，幂等请求失败时换一个端点重新发送
+	LoadBalancer ILoadBalancer
+	// RateLimiter 客户端限流，按 URI key 区分，没有 URI key 时按主机名区分，每次发送（包括重试）前等待令牌，nil 表示不限流
+	RateLimiter IRateLimiter
+	// GzipMinSize 请求体达到这个大小时使用 gzip 压缩发送，0 表示不压缩。gzip 响应总是自动解压
+	GzipMinSize int
+
+	// endpointLists 上次从 URLProvider 读取的以逗号分隔的端点，key 为 URI key
+	endpointLists sync.Map
 }
 
 func (x *APIClient) DoBuffer(client *http.Client, method, url string, configRequest func(*http.Request), bodyObj interface{}) (buffer *bytes.Buffer, err error) {
-	buffer = _bufferPool.GetBuffer()
+	return x.DoBufferContext(context.Background(), client, method, url, configRequest, bodyObj)
+}
 
+func (x *APIClient) DoBufferContext(ctx context.Context, client *http.Client, method, url string, configRequest func(*http.Request), bodyObj interface{}) (buffer *bytes.Buffer, err error) {
 	var resp *http.Response
-	resp, err = x.Do(client, method, url, configRequest, bodyObj)
+	resp, err = x.DoContext(ctx, client, method, url, configRequest, bodyObj)
 	if err != nil {
 		return nil, err
 	}
@@ -27,62 +58,161 @@ func (x *APIClient) DoBuffer(client *http.Client, method, url string, configRequ
 	}()
 
 	// 读取Response Body
+	buffer = _bufferPool.GetBuffer()
 	_, err = buffer.ReadFrom(resp.Body)
 	if err != nil {
-		return nil, err
+		_bufferPool.PutBuffer(buffer)
+		return nil, serr.WithStack(err)
 	}
 
 	return buffer, err
 }
 
 func (x *APIClient) Do(client *http.Client, method, url string, configRequest func(*http.Request), bodyObj interface{}) (resp *http.Response, err error) {
+	return x.DoContext(context.Background(), client, method, url, configRequest, bodyObj)
+}
+
+// DoContext 发送请求，ctx 取消或超时时请求会被中断，超时时间包含所有重试，返回的 resp.Body 必须关闭。
+// Content-Type 根据 bodyObj 的类型设置默认值（见 defaultContentType），configRequest 可以覆盖，
+// 请求体在 configRequest 之后按最终的 Content-Type 编码（见 encodeBody）
+func (x *APIClient) DoContext(ctx context.Context, client *http.Client, method, url string, configRequest func(*http.Request), bodyObj interface{}) (resp *http.Response, err error) {
 	var request *http.Request
 
-	if x.URLProvider != nil {
+	uriKey := surl.GetURIKey(url)
+	rawURL := url
+	balanced := false
+	if x.LoadBalancer != nil && uriKey != "" {
+		if endpoints := x.getEndpoints(uriKey); len(endpoints) > 0 {
+			// 实际的端点在发送时选择
+			url = surl.ReplaceURIKey(url, endpoints[0])
+			balanced = true
+		}
+	}
+	if !balanced && x.URLProvider != nil {
 		// 渲染Url
 		url = x.URLProvider.RenderURLCache(url)
 	}
 
-	// 创建Request
-	bodyBuffer := _bufferPool.GetBuffer()
-	if bodyObj != nil {
-		switch v := bodyObj.(type) {
-		case []byte:
-			bodyBuffer.Write(v)
-			break
-		case string:
-			bodyBuffer.WriteString(v)
-			break
-		default:
-			var body []byte
-			body, err = json.Marshal(v)
-			if err != nil {
-				return nil, err
-			}
-			bodyBuffer.Write(body)
+	if x.Cache != nil {
+		// 嵌套的调用（例如拦截器中获取令牌）也会覆盖，不会继承外层的 URL
+		cacheURL := ""
+		if uriKey != "" {
+			cacheURL = rawURL
 		}
+		ctx = withCacheURL(ctx, cacheURL)
+	}
 
-		request, err = http.NewRequest(method, url, bodyBuffer)
-	} else {
-		request, err = http.NewRequest(method, url, nil)
+	// 超时
+	cancel := func() {}
+	if timeout := getTimeout(ctx, x.Timeout); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
 	}
-	defer func() { _bufferPool.PutBuffer(bodyBuffer) }()
+	defer func() {
+		if err != nil {
+			cancel()
+		}
+	}()
 
+	// 创建Request
+	request, err = http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	// 配置Request
-	request.Header.Set(HEADER_CTYPE, CTYPE_JSON)
+	request.Header.Set(HEADER_CTYPE, defaultContentType(bodyObj))
+	if requestID := GetRequestID(ctx); requestID != "" {
+		request.Header.Set(HEADER_REQUEST_ID, requestID)
+	}
 	if configRequest != nil {
 		configRequest(request)
 	}
 
+	// 按最终的 Content-Type 编码请求体
+	if bodyObj != nil {
+		var body *pooledBody
+		var reader io.Reader
+		body, reader, err = encodeBody(bodyObj, request.Header.Get(HEADER_CTYPE))
+		if err != nil {
+			return nil, err
+		}
+		if body != nil && x.GzipMinSize > 0 && body.buffer.Len() >= x.GzipMinSize && request.Header.Get(HEADER_CONTENT_ENCODING) == "" {
+			body, err = body.compress()
+			if err != nil {
+				return nil, err
+			}
+			request.Header.Set(HEADER_CONTENT_ENCODING, "gzip")
+		}
+		if body != nil {
+			defer body.release()
+			body.attach(request)
+		} else {
+			attachReader(request, reader)
+		}
+	}
+
 	// 发送请求
-	resp, err = client.Do(request)
+	send := Invoker(client.Do)
+	if x.RateLimiter != nil {
+		send = rateLimitSend(x.RateLimiter, uriKey, send)
+	}
+	if x.CircuitBreaker != nil {
+		// 负载均衡时按端点的主机名区分
+		key := uriKey
+		if balanced {
+			key = ""
+		}
+		send = breakerSend(x.CircuitBreaker, key, send)
+	}
+	if interceptors := getInterceptors(ctx, x.Interceptors); len(interceptors) > 0 {
+		send = chainInvoker(interceptors, send)
+	}
+	if balanced {
+		send = balancerSend(x.LoadBalancer, uriKey, rawURL, x.canRetry(request), send)
+	}
+	if x.Cache != nil {
+		// 缓存在最外层，命中时不经过拦截器（获取令牌、签名等）和负载均衡
+		send = chainInvoker([]Interceptor{x.Cache.Interceptor()}, send)
+	}
+	resp, err = x.doWithRetry(ctx, request, send)
+	if err != nil {
+		return nil, err
+	}
+	err = decompressResponse(resp)
 	if err != nil {
 		return nil, err
 	}
 
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
 	return resp, err
 }
+
+// getEndpoints 返回 LoadBalancer 中 key 的端点。URLProvider 返回以逗号分隔的端点时，每次都重新读取，
+// 与上次不同时更新 LoadBalancer，继续保留的端点的统计不变；不再是多个端点时删除之前从 URLProvider 设置的端点
+func (x *APIClient) getEndpoints(key string) []string {
+	if x.URLProvider != nil {
+		v := x.URLProvider.GetURLCache(key)
+		last, found := x.endpointLists.Load(key)
+		if strings.Contains(v, ",") {
+			if !found || last.(string) != v {
+				x.LoadBalancer.SetEndpoints(key, strings.Split(v, ","))
+				x.endpointLists.Store(key, v)
+			}
+		} else if found {
+			x.LoadBalancer.SetEndpoints(key, nil)
+			x.endpointLists.Delete(key)
+		}
+	}
+	return x.LoadBalancer.GetEndpoints(key)
+}
+
+// attachReader 以流的方式发送 reader，与 http.NewRequest 一样为 bytes.Reader 等已知长度的 reader 设置 ContentLength 和 GetBody
+func attachReader(request *http.Request, reader io.Reader) {
+	tmp, err := http.NewRequest(request.Method, "/", reader)
+	if err != nil {
+		return
+	}
+	request.Body = tmp.Body
+	request.ContentLength = tmp.ContentLength
+	request.GetBody = tmp.GetBody
+}
@@ -0,0 +1,256 @@
+package shttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/syncfuture/go/serr"
+	log "github.com/syncfuture/go/slog"
+)
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (x BreakerState) String() string {
+	switch x {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(x))
+}
+
+type ICircuitBreaker interface {
+	// Allow 判断 key 是否允许调用，允许时返回的 done 必须在调用结束后以调用结果调用一次
+	Allow(key string) (done func(resp *http.Response, err error), err error)
+	State(key string) BreakerState
+}
+
+type BreakerConfig struct {
+	// FailureThreshold 连续失败多少次后断开，默认 5
+	FailureThreshold int
+	// CoolDown 断开后多久进入半开状态，默认 30 秒
+	CoolDown time.Duration
+	// HalfOpenMaxCalls 半开状态下同时允许的试探调用数，默认 1
+	HalfOpenMaxCalls int
+	// SuccessThreshold 半开状态下成功多少次后闭合，默认 1
+	SuccessThreshold int
+	// IsFailure 判断调用是否失败，默认 IsBreakerFailure
+	IsFailure func(resp *http.Response, err error) bool
+	// OnStateChange 状态变化时调用，状态变化总是会记录到 slog
+	OnStateChange func(key string, from, to BreakerState)
+}
+
+// BreakerOpenError 熔断器断开时快速失败返回的错误，不会被重试
+type BreakerOpenError struct {
+	Key string
+	// CoolDownLeft 距离进入半开状态的剩余时间，半开状态下试探调用已满时为 CoolDown，即试探失败后需要等待的时间
+	CoolDownLeft time.Duration
+}
+
+func (x *BreakerOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker [%s] is open, retry in %s", x.Key, x.CoolDownLeft)
+}
+
+func (x *BreakerOpenError) Retryable() bool {
+	return false
+}
+
+// IsBreakerFailure 网络错误和 5xx 响应视为失败，调用方主动取消不算
+func IsBreakerFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return !serr.Is(err, context.Canceled)
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+type circuitBreaker struct {
+	config   BreakerConfig
+	lock     sync.Mutex
+	breakers map[string]*breaker
+}
+
+type stateChange struct {
+	key      string
+	from, to BreakerState
+}
+
+type breaker struct {
+	state      BreakerState
+	failures   int
+	successes  int
+	inFlight   int
+	openedAt   time.Time
+	generation uint64
+}
+
+// NewCircuitBreaker 创建按 key（主机名或 surl 的 URI key）区分的熔断器
+func NewCircuitBreaker(config *BreakerConfig) ICircuitBreaker {
+	r := &circuitBreaker{
+		breakers: make(map[string]*breaker),
+	}
+	if config != nil {
+		r.config = *config
+	}
+	if r.config.FailureThreshold <= 0 {
+		r.config.FailureThreshold = 5
+	}
+	if r.config.CoolDown <= 0 {
+		r.config.CoolDown = 30 * time.Second
+	}
+	if r.config.HalfOpenMaxCalls <= 0 {
+		r.config.HalfOpenMaxCalls = 1
+	}
+	if r.config.SuccessThreshold <= 0 {
+		r.config.SuccessThreshold = 1
+	}
+	if r.config.IsFailure == nil {
+		r.config.IsFailure = IsBreakerFailure
+	}
+	return r
+}
+
+func (x *circuitBreaker) Allow(key string) (func(*http.Response, error), error) {
+	var change *stateChange
+	defer func() { x.notify(change) }()
+
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	b := x.get(key)
+	if b.state == BreakerOpen {
+		left := x.config.CoolDown - time.Since(b.openedAt)
+		if left > 0 {
+			return nil, &BreakerOpenError{Key: key, CoolDownLeft: left}
+		}
+		change = x.setState(key, b, BreakerHalfOpen)
+	}
+	if b.state == BreakerHalfOpen {
+		if b.inFlight >= x.config.HalfOpenMaxCalls {
+			return nil, &BreakerOpenError{Key: key, CoolDownLeft: x.config.CoolDown}
+		}
+		b.inFlight++
+	}
+
+	generation := b.generation
+	var once sync.Once
+	return func(resp *http.Response, err error) {
+		once.Do(func() {
+			aborted := err != nil && (serr.Is(err, context.Canceled) || serr.Is(err, context.DeadlineExceeded))
+			x.onDone(key, generation, x.config.IsFailure(resp, err), aborted)
+		})
+	}, nil
+}
+
+func (x *circuitBreaker) State(key string) BreakerState {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.get(key).state
+}
+
+// onDone aborted 表示调用被取消或者超时，没有得到后端的结果
+func (x *circuitBreaker) onDone(key string, generation uint64, failed, aborted bool) {
+	var change *stateChange
+	defer func() { x.notify(change) }()
+
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	b := x.get(key)
+	if b.generation != generation {
+		// 状态已经变化，忽略之前状态下发出的调用结果
+		return
+	}
+
+	switch b.state {
+	case BreakerClosed:
+		if failed {
+			b.failures++
+			if b.failures >= x.config.FailureThreshold {
+				change = x.setState(key, b, BreakerOpen)
+			}
+		} else {
+			b.failures = 0
+		}
+	case BreakerHalfOpen:
+		b.inFlight--
+		if aborted {
+			// 试探没有完成，只释放名额，保持半开
+			return
+		}
+		if failed {
+			change = x.setState(key, b, BreakerOpen)
+		} else {
+			b.successes++
+			if b.successes >= x.config.SuccessThreshold {
+				change = x.setState(key, b, BreakerClosed)
+			}
+		}
+	}
+}
+
+func (x *circuitBreaker) get(key string) *breaker {
+	b, ok := x.breakers[key]
+	if !ok {
+		b = new(breaker)
+		x.breakers[key] = b
+	}
+	return b
+}
+
+func (x *circuitBreaker) setState(key string, b *breaker, state BreakerState) *stateChange {
+	from := b.state
+	b.state = state
+	b.failures = 0
+	b.successes = 0
+	b.inFlight = 0
+	b.generation++
+	if state == BreakerOpen {
+		b.openedAt = time.Now()
+	}
+	return &stateChange{key: key, from: from, to: state}
+}
+
+// notify 在锁外记录日志并调用 OnStateChange
+func (x *circuitBreaker) notify(change *stateChange) {
+	if change == nil {
+		return
+	}
+
+	if change.to == BreakerClosed {
+		log.Infof("circuit breaker [%s] %s -> %s", change.key, change.from, change.to)
+	} else {
+		log.Warnf("circuit breaker [%s] %s -> %s", change.key, change.from, change.to)
+	}
+	if x.config.OnStateChange != nil {
+		x.config.OnStateChange(change.key, change.from, change.to)
+	}
+}
+
+// breakerSend 每次发送（包括重试）都经过熔断器，key 为空时按请求的主机名区分
+func breakerSend(cb ICircuitBreaker, key string, send Invoker) Invoker {
+	return func(r *http.Request) (*http.Response, error) {
+		key := key
+		if key == "" {
+			key = r.URL.Host
+		}
+		done, err := cb.Allow(key)
+		if err != nil {
+			return nil, serr.WithStack(err)
+		}
+		resp, err := send(r)
+		done(resp, err)
+		return resp, err
+	}
+}
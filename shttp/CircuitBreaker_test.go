@@ -0,0 +1,80 @@
+package shttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/syncfuture/go/serr"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	var changes []BreakerState
+	cb := NewCircuitBreaker(&BreakerConfig{
+		FailureThreshold: 2,
+		CoolDown:         50 * time.Millisecond,
+		OnStateChange: func(key string, from, to BreakerState) {
+			changes = append(changes, to)
+		},
+	})
+	apiClient := &APIClient{CircuitBreaker: cb}
+	ctx := context.Background()
+	key := server.Listener.Addr().String()
+
+	for i := 0; i < 2; i++ {
+		resp, err := apiClient.DoContext(ctx, http.DefaultClient, "GET", server.URL, nil, nil)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.Equal(t, BreakerOpen, cb.State(key))
+
+	_, err := apiClient.DoContext(ctx, http.DefaultClient, "GET", server.URL, nil, nil)
+	var openErr *BreakerOpenError
+	assert.True(t, serr.As(err, &openErr))
+	assert.False(t, serr.IsRetryable(err))
+
+	time.Sleep(60 * time.Millisecond)
+	status = http.StatusOK
+	resp, err := apiClient.DoContext(ctx, http.DefaultClient, "GET", server.URL, nil, nil)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, BreakerClosed, cb.State(key))
+	assert.Equal(t, []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerClosed}, changes)
+}
+
+func TestCircuitBreaker_HalfOpenProbe(t *testing.T) {
+	cb := NewCircuitBreaker(&BreakerConfig{FailureThreshold: 1, CoolDown: 20 * time.Millisecond})
+	done, err := cb.Allow("a")
+	assert.NoError(t, err)
+	done(nil, serr.New("connection refused"))
+	assert.Equal(t, BreakerOpen, cb.State("a"))
+
+	time.Sleep(30 * time.Millisecond)
+	probe, err := cb.Allow("a")
+	assert.NoError(t, err)
+	assert.Equal(t, BreakerHalfOpen, cb.State("a"))
+
+	// 试探调用已满时返回真实的等待时间
+	_, err = cb.Allow("a")
+	var openErr *BreakerOpenError
+	assert.True(t, serr.As(err, &openErr))
+	assert.Equal(t, 20*time.Millisecond, openErr.CoolDownLeft)
+
+	// 取消的试探不能证明后端恢复，释放名额后保持半开
+	probe(nil, context.Canceled)
+	assert.Equal(t, BreakerHalfOpen, cb.State("a"))
+
+	probe, err = cb.Allow("a")
+	assert.NoError(t, err)
+	probe(&http.Response{StatusCode: http.StatusOK}, nil)
+	assert.Equal(t, BreakerClosed, cb.State("a"))
+}
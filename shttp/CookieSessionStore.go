@@ -0,0 +1,57 @@
+package shttp
+
+import (
+	"time"
+
+	"github.com/syncfuture/go/serr"
+	log "github.com/syncfuture/go/slog"
+	"github.com/syncfuture/go/ssecurity"
+)
+
+const (
+	_maxCookieSize = 4096
+)
+
+type cookieSessionStore struct {
+	encryptor ssecurity.ICookieEncryptor
+	name      string
+}
+
+// NewCookieSessionStore 会话数据加密后整体保存在 cookie 中，服务端不保存状态，数据不能超过 4KB。
+// name 参与加密，一般与 SessionConfig.CookieName 相同
+func NewCookieSessionStore(name string, encryptor ssecurity.ICookieEncryptor) ISessionStore {
+	if encryptor == nil {
+		log.Fatal("encryptor cannot be nil")
+	}
+	return &cookieSessionStore{
+		encryptor: encryptor,
+		name:      name,
+	}
+}
+
+func (x *cookieSessionStore) Load(cookieValue string) (*SessionData, error) {
+	r := new(SessionData)
+	err := x.encryptor.Decrypt(x.name, cookieValue, r)
+	if err != nil {
+		// 密钥更换或者被篡改，按新会话处理
+		log.Debugf("decrypt session cookie failed: %v", err)
+		return nil, nil
+	}
+	return r, nil
+}
+
+func (x *cookieSessionStore) Save(data *SessionData, ttl time.Duration) (string, error) {
+	r, err := x.encryptor.Encrypt(x.name, data)
+	if err != nil {
+		return "", err
+	}
+	if len(r) > _maxCookieSize {
+		return "", serr.Errorf("session cookie is %d bytes, exceeds %d", len(r), _maxCookieSize)
+	}
+	return r, nil
+}
+
+// Delete 数据只保存在 cookie 中，清除 cookie 即可
+func (x *cookieSessionStore) Delete(data *SessionData) error {
+	return nil
+}
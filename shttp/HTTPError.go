@@ -0,0 +1,126 @@
+package shttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/syncfuture/go/sproto"
+)
+
+const (
+	_maxErrorBodySnippet = 2048
+)
+
+// ErrorDecoder 从非 2xx 响应的 body 中解析业务错误信息，填充到 httpErr
+type ErrorDecoder func(httpErr *HTTPError, body []byte)
+
+// HTTPError 非 2xx 响应转换得到的错误
+type HTTPError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Header     http.Header
+	// Body 响应体的前 2KB
+	Body []byte
+	// MsgCode 由 ErrorDecoder 从响应体中解析出的业务错误码
+	MsgCode string
+	// Result ErrorDecoder 解析出的响应对象，默认为 *sproto.MsgCodeResult
+	Result interface{}
+}
+
+// NewHTTPError 根据响应创建 HTTPError，decoder 为 nil 时使用 DecodeMsgCodeResult
+func NewHTTPError(resp *http.Response, body []byte, decoder ErrorDecoder) *HTTPError {
+	r := &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+	}
+	if resp.Request != nil {
+		r.Method = resp.Request.Method
+		r.URL = resp.Request.URL.String()
+	}
+
+	snippet := body
+	if len(snippet) > _maxErrorBodySnippet {
+		snippet = snippet[:_maxErrorBodySnippet]
+	}
+	r.Body = append([]byte(nil), snippet...)
+
+	if decoder == nil {
+		decoder = DecodeMsgCodeResult
+	}
+	decoder(r, body)
+
+	return r
+}
+
+// DecodeMsgCodeResult 默认的 ErrorDecoder，把 JSON 响应体解析为 sproto.MsgCodeResult
+func DecodeMsgCodeResult(httpErr *HTTPError, body []byte) {
+	if len(body) == 0 || !strings.Contains(httpErr.Header.Get(HEADER_CTYPE), "json") {
+		return
+	}
+
+	r := new(sproto.MsgCodeResult)
+	if err := json.Unmarshal(body, r); err == nil && r.MsgCode != "" {
+		httpErr.MsgCode = r.MsgCode
+		httpErr.Result = r
+	}
+}
+
+func (x *HTTPError) Error() string {
+	if x.MsgCode != "" {
+		return fmt.Sprintf("%s %s [%d] -> %s", x.Method, x.URL, x.StatusCode, x.MsgCode)
+	}
+	return fmt.Sprintf("%s %s [%d] -> %s", x.Method, x.URL, x.StatusCode, x.Body)
+}
+
+// Code 实现 serr.GetCode，返回业务错误码
+func (x *HTTPError) Code() string {
+	return x.MsgCode
+}
+
+// Retryable 429、502、503、504 可以重试
+func (x *HTTPError) Retryable() bool {
+	return IsRetryableStatus(x.StatusCode)
+}
+
+// RetryAfter 解析 Retry-After 响应头
+func (x *HTTPError) RetryAfter() time.Duration {
+	return parseRetryAfter(x.Header.Get(HEADER_RETRY_AFTER))
+}
+
+func IsRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func IsSuccessStatus(statusCode int) bool {
+	return statusCode >= 200 && statusCode < 300
+}
+
+// parseRetryAfter Retry-After 可以是秒数或者 HTTP 日期
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,327 @@
+package shttp
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syncfuture/go/serr"
+	log "github.com/syncfuture/go/slog"
+	"github.com/syncfuture/go/surl"
+)
+
+type BalancePolicy int
+
+const (
+	// BalanceRoundRobin 依次使用每个端点
+	BalanceRoundRobin BalancePolicy = iota
+	// BalanceLeastOutstanding 使用未完成请求最少的端点
+	BalanceLeastOutstanding
+)
+
+// ILoadBalancer 把同一个 URI key 的请求分发到多个端点
+type ILoadBalancer interface {
+	// SetEndpoints 设置 key 的端点（base URL），已有端点的统计信息会保留
+	SetEndpoints(key string, endpoints []string)
+	GetEndpoints(key string) []string
+	Stats(key string) []EndpointStats
+	// Pick 选择一个不在 exclude 中的端点，调用结束时必须调用 done，key 没有可用端点时 ok 为 false
+	Pick(key string, exclude map[string]bool) (endpoint string, done func(*http.Response, error), ok bool)
+}
+
+// EndpointStats 端点的统计信息，用于监控
+type EndpointStats struct {
+	Endpoint    string
+	Outstanding int
+	Requests    uint64
+	Failures    uint64
+	// ConsecutiveFailures 连续失败次数，达到 FailureThreshold 时端点被摘除
+	ConsecutiveFailures int
+	Ejected             bool
+	EjectedUntil        time.Time
+}
+
+type LoadBalancerConfig struct {
+	Policy BalancePolicy
+	// FailureThreshold 连续失败多少次后摘除端点，默认 3
+	FailureThreshold int
+	// EjectDuration 摘除的时间，到期后放行一个探测请求，成功则恢复，失败则再次摘除并加倍时间，默认 30 秒
+	EjectDuration time.Duration
+	// MaxEjectDuration 摘除时间的上限，默认 5 分钟
+	MaxEjectDuration time.Duration
+	// IsFailure 判断调用是否失败，默认 IsBreakerFailure
+	IsFailure func(*http.Response, error) bool
+}
+
+type loadBalancer struct {
+	config LoadBalancerConfig
+	lock   sync.Mutex
+	groups map[string]*endpointGroup
+}
+
+type endpointGroup struct {
+	endpoints []*endpoint
+	next      int
+}
+
+type endpoint struct {
+	EndpointStats
+	ejections int
+	probing   bool
+}
+
+func NewLoadBalancer(config *LoadBalancerConfig) ILoadBalancer {
+	r := &loadBalancer{
+		groups: make(map[string]*endpointGroup),
+	}
+	if config != nil {
+		r.config = *config
+	}
+	if r.config.FailureThreshold <= 0 {
+		r.config.FailureThreshold = 3
+	}
+	if r.config.EjectDuration <= 0 {
+		r.config.EjectDuration = 30 * time.Second
+	}
+	if r.config.MaxEjectDuration <= 0 {
+		r.config.MaxEjectDuration = 5 * time.Minute
+	}
+	if r.config.IsFailure == nil {
+		r.config.IsFailure = IsBreakerFailure
+	}
+	return r
+}
+
+func (x *loadBalancer) SetEndpoints(key string, endpoints []string) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	old := make(map[string]*endpoint)
+	if g, ok := x.groups[key]; ok {
+		for _, e := range g.endpoints {
+			old[e.Endpoint] = e
+		}
+	}
+
+	g := new(endpointGroup)
+	for _, s := range endpoints {
+		s = strings.TrimRight(strings.TrimSpace(s), "/")
+		if s == "" {
+			continue
+		}
+		e, ok := old[s]
+		if !ok {
+			e = &endpoint{EndpointStats: EndpointStats{Endpoint: s}}
+		}
+		g.endpoints = append(g.endpoints, e)
+	}
+	if len(g.endpoints) == 0 {
+		delete(x.groups, key)
+		return
+	}
+	x.groups[key] = g
+}
+
+func (x *loadBalancer) GetEndpoints(key string) []string {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	g, ok := x.groups[key]
+	if !ok {
+		return nil
+	}
+	r := make([]string, len(g.endpoints))
+	for i, e := range g.endpoints {
+		r[i] = e.Endpoint
+	}
+	return r
+}
+
+func (x *loadBalancer) Stats(key string) []EndpointStats {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	g, ok := x.groups[key]
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	r := make([]EndpointStats, len(g.endpoints))
+	for i, e := range g.endpoints {
+		r[i] = e.EndpointStats
+		r[i].Ejected = now.Before(e.EjectedUntil)
+	}
+	return r
+}
+
+func (x *loadBalancer) Pick(key string, exclude map[string]bool) (string, func(*http.Response, error), bool) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	g, ok := x.groups[key]
+	if !ok {
+		return "", nil, false
+	}
+
+	now := time.Now()
+	var picked *endpoint
+	var fallback *endpoint
+	n := len(g.endpoints)
+	for i := 0; i < n; i++ {
+		e := g.endpoints[(g.next+i)%n]
+		if exclude[e.Endpoint] {
+			continue
+		}
+		if now.Before(e.EjectedUntil) || e.probing {
+			// 全部端点都被摘除时使用最早到期的端点，而不是直接失败
+			if fallback == nil || e.EjectedUntil.Before(fallback.EjectedUntil) {
+				fallback = e
+			}
+			continue
+		}
+		if e.ConsecutiveFailures >= x.config.FailureThreshold {
+			// 摘除到期，作为探测请求放行，结果返回前不再放行其他请求
+			picked = e
+			e.probing = true
+			break
+		}
+		if picked == nil || (x.config.Policy == BalanceLeastOutstanding && e.Outstanding < picked.Outstanding) {
+			picked = e
+			if x.config.Policy == BalanceRoundRobin {
+				break
+			}
+		}
+	}
+	if picked == nil {
+		picked = fallback
+	}
+	if picked == nil {
+		return "", nil, false
+	}
+
+	for i, e := range g.endpoints {
+		if e == picked {
+			g.next = (i + 1) % n
+			break
+		}
+	}
+	picked.Outstanding++
+	picked.Requests++
+
+	var once sync.Once
+	return picked.Endpoint, func(resp *http.Response, err error) {
+		once.Do(func() {
+			x.onDone(key, picked, x.config.IsFailure(resp, err))
+		})
+	}, true
+}
+
+func (x *loadBalancer) onDone(key string, e *endpoint, failed bool) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	e.Outstanding--
+	e.probing = false
+	if !failed {
+		if e.ConsecutiveFailures >= x.config.FailureThreshold {
+			log.Infof("endpoint %s of [%s] recovered", e.Endpoint, key)
+		}
+		e.ConsecutiveFailures = 0
+		e.ejections = 0
+		return
+	}
+
+	e.Failures++
+	e.ConsecutiveFailures++
+	if e.ConsecutiveFailures >= x.config.FailureThreshold {
+		d := x.config.EjectDuration << uint(e.ejections)
+		if d <= 0 || d > x.config.MaxEjectDuration {
+			d = x.config.MaxEjectDuration
+		} else {
+			e.ejections++
+		}
+		e.EjectedUntil = time.Now().Add(d)
+		log.Warnf("endpoint %s of [%s] ejected for %s after %d consecutive failures", e.Endpoint, key, d, e.ConsecutiveFailures)
+	}
+}
+
+// balancerSend 为每次发送选择端点并改写请求的 URL，canFailover 为 true 时（幂等请求），
+// 网络错误和 502/503/504 响应会换一个端点重新发送，直到所有端点都试过
+func balancerSend(lb ILoadBalancer, key, rawURL string, canFailover bool, send Invoker) Invoker {
+	return func(request *http.Request) (*http.Response, error) {
+		var tried map[string]bool
+		for {
+			endpoint, done, ok := lb.Pick(key, tried)
+			if !ok {
+				return nil, serr.Errorf("no endpoint available for [%s]", key)
+			}
+
+			req := request
+			if tried != nil {
+				req = request.Clone(request.Context())
+				if request.GetBody != nil {
+					body, err := request.GetBody()
+					if err != nil {
+						done(nil, err)
+						return nil, serr.WithStack(err)
+					}
+					req.Body = body
+				}
+			}
+			u, err := url.Parse(surl.ReplaceURIKey(rawURL, endpoint))
+			if err != nil {
+				done(nil, err)
+				return nil, serr.WithStack(err)
+			}
+			req.URL = u
+			req.Host = ""
+
+			resp, err := send(req)
+			failover := canFailover && request.Context().Err() == nil &&
+				(err != nil || resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusGatewayTimeout)
+			if failover {
+				if tried == nil {
+					tried = make(map[string]bool)
+				}
+				tried[endpoint] = true
+				if len(tried) < len(lb.GetEndpoints(key)) {
+					done(resp, err)
+					if err == nil {
+						drainAndClose(resp.Body)
+					}
+					log.Warnf("%s %s failed on %s, failing over: %v", req.Method, req.URL.Path, endpoint, failoverReason(resp, err))
+					continue
+				}
+			}
+
+			if err != nil {
+				done(nil, err)
+				return nil, err
+			}
+			resp.Body = &doneBody{ReadCloser: resp.Body, done: func() { done(resp, nil) }}
+			return resp, nil
+		}
+	}
+}
+
+func failoverReason(resp *http.Response, err error) interface{} {
+	if err != nil {
+		return err
+	}
+	return resp.Status
+}
+
+// doneBody 响应体关闭时结束对端点的占用，使 BalanceLeastOutstanding 统计包含读取响应体的时间
+type doneBody struct {
+	io.ReadCloser
+	done func()
+}
+
+func (x *doneBody) Close() error {
+	err := x.ReadCloser.Close()
+	x.done()
+	return err
+}
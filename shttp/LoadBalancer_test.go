@@ -0,0 +1,120 @@
+package shttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/syncfuture/go/surl"
+)
+
+func TestLoadBalancer_Pick(t *testing.T) {
+	lb := NewLoadBalancer(&LoadBalancerConfig{Policy: BalanceLeastOutstanding})
+	lb.SetEndpoints("svc", []string{"http://a/", "http://b", "http://c"})
+	assert.Equal(t, []string{"http://a", "http://b", "http://c"}, lb.GetEndpoints("svc"))
+
+	a, doneA, ok := lb.Pick("svc", nil)
+	assert.True(t, ok)
+	b, doneB, _ := lb.Pick("svc", nil)
+	c, doneC, _ := lb.Pick("svc", nil)
+	assert.ElementsMatch(t, []string{"http://a", "http://b", "http://c"}, []string{a, b, c})
+
+	// b 完成后未完成请求最少
+	ok200 := &http.Response{StatusCode: http.StatusOK}
+	doneB(ok200, nil)
+	e, doneE, _ := lb.Pick("svc", nil)
+	assert.Equal(t, b, e)
+	doneA(ok200, nil)
+	doneC(ok200, nil)
+	doneE(ok200, nil)
+
+	e, _, _ = lb.Pick("svc", map[string]bool{"http://a": true, "http://b": true})
+	assert.Equal(t, "http://c", e)
+
+	_, _, ok = lb.Pick("missing", nil)
+	assert.False(t, ok)
+}
+
+func TestAPIClient_LoadBalancer(t *testing.T) {
+	var healthy int32
+	var hits [2]int32
+	newServer := func(i int, flaky bool) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&hits[i], 1)
+			if flaky && atomic.LoadInt32(&healthy) == 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(r.URL.Path))
+		}))
+	}
+	good, bad := newServer(0, false), newServer(1, true)
+	defer good.Close()
+	defer bad.Close()
+
+	lb := NewLoadBalancer(&LoadBalancerConfig{FailureThreshold: 2, EjectDuration: 50 * time.Millisecond})
+	lb.SetEndpoints("svc", []string{bad.URL, good.URL})
+	apiClient := &APIClient{LoadBalancer: lb}
+	ctx := context.Background()
+
+	// 幂等请求在失败的端点上换一个端点
+	for i := 0; i < 6; i++ {
+		buffer, err := apiClient.DoBufferContext(ctx, http.DefaultClient, http.MethodGet, "{{URI 'svc'}}/items", nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "/items", buffer.String())
+		RecycleBuffer(buffer)
+	}
+	// 连续失败 2 次后被摘除，之后不再收到请求
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits[1]))
+	stats := lb.Stats("svc")
+	assert.True(t, stats[0].Ejected)
+	assert.Equal(t, uint64(2), stats[0].Failures)
+
+	// 非幂等请求不换端点
+	lb.SetEndpoints("post", []string{bad.URL})
+	resp, err := apiClient.DoContext(ctx, http.DefaultClient, http.MethodPost, "{{URI 'post'}}/items", nil, "x")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	resp.Body.Close()
+
+	// 摘除到期后探测成功，端点恢复
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 4; i++ {
+		err = apiClient.DoJSON(ctx, http.DefaultClient, http.MethodGet, "{{URI 'svc'}}/items", nil, nil, nil)
+		assert.NoError(t, err)
+	}
+	stats = lb.Stats("svc")
+	assert.False(t, stats[0].Ejected)
+	assert.Equal(t, 0, stats[0].ConsecutiveFailures)
+	assert.True(t, atomic.LoadInt32(&hits[1]) > 2)
+}
+
+type testURLProvider struct {
+	urls map[string]string
+}
+
+func (x *testURLProvider) GetURL(urlKey string) string      { return x.urls[urlKey] }
+func (x *testURLProvider) GetURLCache(urlKey string) string { return x.urls[urlKey] }
+func (x *testURLProvider) RenderURL(url string) string {
+	return surl.ReplaceURIKey(url, x.urls[surl.GetURIKey(url)])
+}
+func (x *testURLProvider) RenderURLCache(url string) string { return x.RenderURL(url) }
+
+func TestAPIClient_LoadBalancerEndpointsFromURLProvider(t *testing.T) {
+	provider := &testURLProvider{urls: map[string]string{"svc": "http://a,http://b"}}
+	apiClient := &APIClient{URLProvider: provider, LoadBalancer: NewLoadBalancer(nil)}
+	assert.Equal(t, []string{"http://a", "http://b"}, apiClient.getEndpoints("svc"))
+
+	// 配置变化后更新端点
+	provider.urls["svc"] = "http://b,http://c"
+	assert.Equal(t, []string{"http://b", "http://c"}, apiClient.getEndpoints("svc"))
+
+	// 只剩一个地址时不再负载均衡
+	provider.urls["svc"] = "http://c"
+	assert.Empty(t, apiClient.getEndpoints("svc"))
+}
@@ -0,0 +1,80 @@
+package shttp
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type memoryCacheStore struct {
+	maxEntries int
+	lock       sync.Mutex
+	lru        *list.List
+	items      map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key      string
+	entry    *CachedResponse
+	deadline time.Time
+}
+
+// NewMemoryCacheStore 创建最多保存 maxEntries 条的内存 LRU 缓存
+func NewMemoryCacheStore(maxEntries int) ICacheStore {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &memoryCacheStore{
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (x *memoryCacheStore) Get(key string) (*CachedResponse, bool) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	e, ok := x.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := e.Value.(*memoryCacheItem)
+	if time.Now().After(item.deadline) {
+		x.remove(e)
+		return nil, false
+	}
+	x.lru.MoveToFront(e)
+	return item.entry, true
+}
+
+func (x *memoryCacheStore) Set(key string, entry *CachedResponse, ttl time.Duration) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	item := &memoryCacheItem{key: key, entry: entry, deadline: time.Now().Add(ttl)}
+	if e, ok := x.items[key]; ok {
+		e.Value = item
+		x.lru.MoveToFront(e)
+		return
+	}
+
+	x.items[key] = x.lru.PushFront(item)
+	for x.lru.Len() > x.maxEntries {
+		x.remove(x.lru.Back())
+	}
+}
+
+func (x *memoryCacheStore) Delete(key string) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	if e, ok := x.items[key]; ok {
+		x.remove(e)
+	}
+}
+
+func (x *memoryCacheStore) remove(e *list.Element) {
+	x.lru.Remove(e)
+	delete(x.items, e.Value.(*memoryCacheItem).key)
+}
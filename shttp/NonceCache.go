@@ -0,0 +1,58 @@
+package shttp
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/patrickmn/go-cache"
+	"github.com/syncfuture/go/serr"
+	log "github.com/syncfuture/go/slog"
+	"github.com/syncfuture/go/sredis"
+)
+
+// INonceCache 记录使用过的 nonce，用于拒绝重放的请求
+type INonceCache interface {
+	// Add 记录 nonce，第一次出现时返回 true
+	Add(nonce string, ttl time.Duration) (bool, error)
+}
+
+type memoryNonceCache struct {
+	cache *cache.Cache
+}
+
+// NewMemoryNonceCache 进程内的 nonce 缓存，只适用于单实例部署
+func NewMemoryNonceCache() INonceCache {
+	return &memoryNonceCache{
+		cache: cache.New(10*time.Minute, time.Minute),
+	}
+}
+
+func (x *memoryNonceCache) Add(nonce string, ttl time.Duration) (bool, error) {
+	return x.cache.Add(nonce, struct{}{}, ttl) == nil, nil
+}
+
+type redisNonceCache struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisNonceCache 使用 Redis 保存的 nonce 缓存，多个实例共享，key 为 prefix + nonce
+func NewRedisNonceCache(prefix string, config *sredis.RedisConfig) INonceCache {
+	return NewRedisNonceCacheWithClient(prefix, sredis.NewClient(config))
+}
+
+func NewRedisNonceCacheWithClient(prefix string, client redis.Cmdable) INonceCache {
+	if prefix == "" {
+		log.Fatal("prefix cannot be empty")
+	}
+	return &redisNonceCache{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (x *redisNonceCache) Add(nonce string, ttl time.Duration) (bool, error) {
+	r, err := x.client.SetNX(context.Background(), x.prefix+nonce, 1, ttl).Result()
+	return r, serr.WithStack(err)
+}
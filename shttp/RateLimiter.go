@@ -0,0 +1,275 @@
+package shttp
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/syncfuture/go/serr"
+)
+
+// IRateLimiter 客户端令牌桶限流，按 key（surl 的 URI key，没有时为主机名）区分
+type IRateLimiter interface {
+	// Wait 等待 key 的一个令牌，ctx 结束或者预计等待时间超过 MaxWait 时不消耗令牌，返回错误
+	Wait(ctx context.Context, key string) error
+	// SetLimit 设置 key 的限制，覆盖 RateLimiterConfig 中的配置
+	SetLimit(key string, limit RateLimit)
+	// Stats 返回所有用过的 key 的统计信息，按 key 排序
+	Stats() []RateLimiterStats
+}
+
+type RateLimit struct {
+	// Rate 每秒的请求数，0 表示不限制
+	Rate float64
+	// Burst 桶的容量，即空闲后可以连续发送的请求数，默认为 Rate 向上取整
+	Burst int
+}
+
+type RateLimiterConfig struct {
+	// Default 没有在 Limits 中配置的 key 使用的限制，零值表示不限制
+	Default RateLimit
+	// Limits 按 key 配置的限制
+	Limits map[string]RateLimit
+	// MaxWait 单次等待时间的上限，0 表示只受 ctx 限制
+	MaxWait time.Duration
+}
+
+// RateLimiterStats 限流的统计信息，Redis 模式下只统计当前进程
+type RateLimiterStats struct {
+	Key   string
+	Limit RateLimit
+	// Allowed 拿到令牌的请求数，包括等待后拿到的
+	Allowed uint64
+	// Delayed 需要等待才拿到令牌的请求数
+	Delayed uint64
+	// Rejected 等不到令牌的请求数
+	Rejected uint64
+	// TotalWait 累计等待的时间
+	TotalWait time.Duration
+}
+
+// RateLimitError ctx 结束前或者 MaxWait 内等不到令牌时返回的错误，不会被重试
+type RateLimitError struct {
+	Key string
+	// Wait 预计需要等待的时间
+	Wait time.Duration
+}
+
+func (x *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit [%s] exceeded, need to wait %s", x.Key, x.Wait)
+}
+
+func (x *RateLimitError) Retryable() bool {
+	return false
+}
+
+// tokenStore 保存令牌桶的状态
+type tokenStore interface {
+	// take 取一个令牌，返回拿到令牌前需要等待的时间，等待时间超过 maxWait（小于 0 表示不限）时不取，ok 为 false
+	take(key string, limit RateLimit, maxWait time.Duration) (wait time.Duration, ok bool, err error)
+	// giveBack 归还一个取了但是没有用到的令牌
+	giveBack(key string, limit RateLimit) error
+}
+
+type rateLimiter struct {
+	config RateLimiterConfig
+	store  tokenStore
+	lock   sync.Mutex
+	limits map[string]RateLimit
+	stats  map[string]*RateLimiterStats
+}
+
+// NewRateLimiter 进程内的限流器，多个进程共享配额时使用 NewRedisRateLimiter
+func NewRateLimiter(config *RateLimiterConfig) IRateLimiter {
+	return newRateLimiter(config, newMemoryTokenStore())
+}
+
+func newRateLimiter(config *RateLimiterConfig, store tokenStore) *rateLimiter {
+	r := &rateLimiter{
+		store:  store,
+		limits: make(map[string]RateLimit),
+		stats:  make(map[string]*RateLimiterStats),
+	}
+	if config != nil {
+		r.config = *config
+	}
+	for k, v := range r.config.Limits {
+		r.limits[k] = v.normalize()
+	}
+	r.config.Default = r.config.Default.normalize()
+	return r
+}
+
+func (x RateLimit) normalize() RateLimit {
+	if x.Rate > 0 && x.Burst <= 0 {
+		x.Burst = int(math.Ceil(x.Rate))
+	}
+	return x
+}
+
+func (x *rateLimiter) SetLimit(key string, limit RateLimit) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.limits[key] = limit.normalize()
+	if s, ok := x.stats[key]; ok {
+		s.Limit = x.limits[key]
+	}
+}
+
+func (x *rateLimiter) Stats() []RateLimiterStats {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	r := make([]RateLimiterStats, 0, len(x.stats))
+	for _, s := range x.stats {
+		r = append(r, *s)
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].Key < r[j].Key })
+	return r
+}
+
+func (x *rateLimiter) Wait(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return serr.WithStack(err)
+	}
+
+	x.lock.Lock()
+	limit, ok := x.limits[key]
+	if !ok {
+		limit = x.config.Default
+	}
+	stats, ok := x.stats[key]
+	if !ok {
+		stats = &RateLimiterStats{Key: key, Limit: limit}
+		x.stats[key] = stats
+	}
+	x.lock.Unlock()
+	if limit.Rate <= 0 {
+		x.record(stats, 0, true)
+		return nil
+	}
+
+	maxWait := time.Duration(-1)
+	if x.config.MaxWait > 0 {
+		maxWait = x.config.MaxWait
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if left := time.Until(deadline); maxWait < 0 || left < maxWait {
+			maxWait = left
+		}
+	}
+
+	wait, ok, err := x.store.take(key, limit, maxWait)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		x.record(stats, 0, false)
+		return serr.WithStack(&RateLimitError{Key: key, Wait: wait})
+	}
+	if wait <= 0 {
+		x.record(stats, 0, true)
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		x.record(stats, wait, true)
+		return nil
+	case <-ctx.Done():
+		x.record(stats, 0, false)
+		x.store.giveBack(key, limit)
+		return serr.WithStack(ctx.Err())
+	}
+}
+
+func (x *rateLimiter) record(stats *RateLimiterStats, wait time.Duration, allowed bool) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if !allowed {
+		stats.Rejected++
+		return
+	}
+	stats.Allowed++
+	if wait > 0 {
+		stats.Delayed++
+		stats.TotalWait += wait
+	}
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type memoryTokenStore struct {
+	lock    sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newMemoryTokenStore() *memoryTokenStore {
+	return &memoryTokenStore{
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// bucket 返回补充了令牌的桶
+func (x *memoryTokenStore) bucket(key string, limit RateLimit, now time.Time) *tokenBucket {
+	b, ok := x.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(limit.Burst), last: now}
+		x.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed.Seconds()*limit.Rate)
+		b.last = now
+	}
+	return b
+}
+
+func (x *memoryTokenStore) take(key string, limit RateLimit, maxWait time.Duration) (time.Duration, bool, error) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	b := x.bucket(key, limit, time.Now())
+	// 令牌可以为负数，表示已经预约给等待中的请求
+	tokens := b.tokens - 1
+	var wait time.Duration
+	if tokens < 0 {
+		wait = time.Duration(-tokens / limit.Rate * float64(time.Second))
+	}
+	if maxWait >= 0 && wait > maxWait {
+		return wait, false, nil
+	}
+	b.tokens = tokens
+	return wait, true, nil
+}
+
+func (x *memoryTokenStore) giveBack(key string, limit RateLimit) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	b := x.bucket(key, limit, time.Now())
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+1)
+	return nil
+}
+
+// rateLimitSend 发送前等待 key 的令牌，key 为空时使用请求 URL 的主机名，重试的每次发送都需要令牌
+func rateLimitSend(limiter IRateLimiter, key string, send Invoker) Invoker {
+	return func(r *http.Request) (*http.Response, error) {
+		key := key
+		if key == "" {
+			key = r.URL.Host
+		}
+		if err := limiter.Wait(r.Context(), key); err != nil {
+			return nil, err
+		}
+		return send(r)
+	}
+}
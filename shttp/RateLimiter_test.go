@@ -0,0 +1,103 @@
+package shttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/syncfuture/go/serr"
+)
+
+func TestRateLimiter_Wait(t *testing.T) {
+	limiter := NewRateLimiter(&RateLimiterConfig{
+		Limits: map[string]RateLimit{"svc": {Rate: 20, Burst: 2}},
+	})
+	ctx := context.Background()
+
+	// 桶满时不需要等待
+	start := time.Now()
+	assert.NoError(t, limiter.Wait(ctx, "svc"))
+	assert.NoError(t, limiter.Wait(ctx, "svc"))
+	assert.True(t, time.Since(start) < 20*time.Millisecond)
+
+	// 之后每 50ms 一个令牌
+	assert.NoError(t, limiter.Wait(ctx, "svc"))
+	assert.True(t, time.Since(start) >= 40*time.Millisecond)
+
+	// 截止时间前等不到令牌时立即返回，不消耗令牌
+	shortCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	err := limiter.Wait(shortCtx, "svc")
+	cancel()
+	var rateLimitErr *RateLimitError
+	assert.True(t, serr.As(err, &rateLimitErr))
+	assert.Equal(t, "svc", rateLimitErr.Key)
+	assert.Equal(t, http.StatusServiceUnavailable, ErrorStatus(err))
+
+	// 等待中取消时归还令牌
+	cancelCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	err = limiter.Wait(cancelCtx, "svc")
+	assert.True(t, serr.Is(err, context.Canceled))
+	start = time.Now()
+	assert.NoError(t, limiter.Wait(ctx, "svc"))
+	assert.True(t, time.Since(start) < 60*time.Millisecond)
+
+	// 没有配置的 key 不限制
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, limiter.Wait(ctx, "other"))
+	}
+
+	stats := limiter.Stats()
+	assert.Len(t, stats, 2)
+	assert.Equal(t, "other", stats[0].Key)
+	assert.Equal(t, uint64(100), stats[0].Allowed)
+	assert.Equal(t, "svc", stats[1].Key)
+	assert.Equal(t, uint64(4), stats[1].Allowed)
+	assert.Equal(t, uint64(2), stats[1].Rejected)
+	assert.True(t, stats[1].Delayed >= 1)
+	assert.True(t, stats[1].TotalWait > 0)
+}
+
+func TestAPIClient_RateLimiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	limiter := NewRateLimiter(&RateLimiterConfig{
+		Default: RateLimit{Rate: 50, Burst: 1},
+		MaxWait: 30 * time.Millisecond,
+	})
+	apiClient := &APIClient{RateLimiter: limiter}
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		err := apiClient.DoJSON(ctx, http.DefaultClient, http.MethodGet, server.URL, nil, nil, nil)
+		assert.NoError(t, err)
+	}
+	assert.True(t, time.Since(start) >= 40*time.Millisecond)
+
+	// 预计等待超过 MaxWait 时快速失败
+	limiter.SetLimit(server.Listener.Addr().String(), RateLimit{Rate: 1})
+	err := apiClient.DoJSON(ctx, http.DefaultClient, http.MethodGet, server.URL, nil, nil, nil)
+	var rateLimitErr *RateLimitError
+	assert.True(t, serr.As(err, &rateLimitErr))
+
+	w := httptest.NewRecorder()
+	WriteError(w, httptest.NewRequest(http.MethodGet, "/", nil), err)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.NotEmpty(t, w.Header().Get(HEADER_RETRY_AFTER))
+
+	stats := limiter.Stats()
+	assert.Len(t, stats, 1)
+	assert.Equal(t, RateLimit{Rate: 1, Burst: 1}, stats[0].Limit)
+	assert.Equal(t, uint64(3), stats[0].Allowed)
+	assert.Equal(t, uint64(1), stats[0].Rejected)
+}
@@ -0,0 +1,68 @@
+package shttp
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	log "github.com/syncfuture/go/slog"
+	"github.com/syncfuture/go/sredis"
+)
+
+type redisCacheStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisCacheStore 创建使用 Redis 保存的缓存，多个进程可以共享，key 为 prefix + 请求
+func NewRedisCacheStore(prefix string, config *sredis.RedisConfig) ICacheStore {
+	return NewRedisCacheStoreWithClient(prefix, sredis.NewClient(config))
+}
+
+func NewRedisCacheStoreWithClient(prefix string, client redis.Cmdable) ICacheStore {
+	if prefix == "" {
+		log.Fatal("prefix cannot be empty")
+	}
+	return &redisCacheStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (x *redisCacheStore) Get(key string) (*CachedResponse, bool) {
+	data, err := x.client.Get(context.Background(), x.prefix+key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			log.Warn(err)
+		}
+		return nil, false
+	}
+
+	r := new(CachedResponse)
+	err = json.Unmarshal(data, r)
+	if err != nil {
+		log.Warn(err)
+		return nil, false
+	}
+	return r, true
+}
+
+func (x *redisCacheStore) Set(key string, entry *CachedResponse, ttl time.Duration) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Warn(err)
+		return
+	}
+	err = x.client.Set(context.Background(), x.prefix+key, data, ttl).Err()
+	if err != nil {
+		log.Warn(err)
+	}
+}
+
+func (x *redisCacheStore) Delete(key string) {
+	err := x.client.Del(context.Background(), x.prefix+key).Err()
+	if err != nil {
+		log.Warn(err)
+	}
+}
@@ -0,0 +1,87 @@
+package shttp
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/syncfuture/go/serr"
+	log "github.com/syncfuture/go/slog"
+	"github.com/syncfuture/go/sredis"
+)
+
+// _takeTokenScript 使用 Redis 的时间计算令牌，各个进程的时钟不需要一致，时间单位为毫秒。
+// 返回 {是否拿到令牌, 需要等待的毫秒数}
+var _takeTokenScript = redis.NewScript(`
+redis.replicate_commands()
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local max_wait = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local v = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(v[1]) or burst
+local ts = tonumber(v[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000) - 1
+local wait = 0
+if tokens < 0 then
+	wait = math.ceil(-tokens * 1000 / rate)
+end
+if max_wait >= 0 and wait > max_wait then
+	return {0, wait}
+end
+redis.call('HMSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) * 1000 / rate) + 1000)
+return {1, wait}
+`)
+
+var _giveBackTokenScript = redis.NewScript(`
+local tokens = tonumber(redis.call('HGET', KEYS[1], 'tokens'))
+if tokens then
+	redis.call('HSET', KEYS[1], 'tokens', math.min(tokens + 1, tonumber(ARGV[1])))
+end
+return 0
+`)
+
+type redisTokenStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisRateLimiter 使用 Redis 保存令牌桶的限流器，多个进程共享配额，key 为 prefix + 限流的 key
+func NewRedisRateLimiter(prefix string, config *RateLimiterConfig, redisConfig *sredis.RedisConfig) IRateLimiter {
+	return NewRedisRateLimiterWithClient(prefix, config, sredis.NewClient(redisConfig))
+}
+
+func NewRedisRateLimiterWithClient(prefix string, config *RateLimiterConfig, client redis.Cmdable) IRateLimiter {
+	if prefix == "" {
+		log.Fatal("prefix cannot be empty")
+	}
+	return newRateLimiter(config, &redisTokenStore{
+		client: client,
+		prefix: prefix,
+	})
+}
+
+func (x *redisTokenStore) take(key string, limit RateLimit, maxWait time.Duration) (time.Duration, bool, error) {
+	maxWaitMS := int64(-1)
+	if maxWait >= 0 {
+		maxWaitMS = maxWait.Milliseconds()
+	}
+	v, err := _takeTokenScript.Run(context.Background(), x.client, []string{x.prefix + key}, limit.Rate, limit.Burst, maxWaitMS).Result()
+	if err != nil {
+		return 0, false, serr.WithStack(err)
+	}
+	r, _ := v.([]interface{})
+	if len(r) != 2 {
+		return 0, false, serr.Errorf("unexpected rate limit script result %v", v)
+	}
+	ok, _ := r[0].(int64)
+	wait, _ := r[1].(int64)
+	return time.Duration(wait) * time.Millisecond, ok == 1, nil
+}
+
+func (x *redisTokenStore) giveBack(key string, limit RateLimit) error {
+	err := _giveBackTokenScript.Run(context.Background(), x.client, []string{x.prefix + key}, limit.Burst).Err()
+	return serr.WithStack(err)
+}
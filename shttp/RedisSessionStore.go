@@ -0,0 +1,85 @@
+package shttp
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/syncfuture/go/serr"
+	log "github.com/syncfuture/go/slog"
+	"github.com/syncfuture/go/sredis"
+	"github.com/syncfuture/go/ssecurity"
+)
+
+const (
+	_sessionIDCookieName = "session-id"
+)
+
+type redisSessionStore struct {
+	client redis.Cmdable
+	prefix string
+	signer ssecurity.ICookieEncryptor
+}
+
+// NewRedisSessionStore 会话数据保存在 Redis 中，key 为 prefix + 会话 ID，cookie 中只保存用 signer 签名的会话 ID
+func NewRedisSessionStore(prefix string, signer ssecurity.ICookieEncryptor, config *sredis.RedisConfig) ISessionStore {
+	return NewRedisSessionStoreWithClient(prefix, signer, sredis.NewClient(config))
+}
+
+func NewRedisSessionStoreWithClient(prefix string, signer ssecurity.ICookieEncryptor, client redis.Cmdable) ISessionStore {
+	if prefix == "" {
+		log.Fatal("prefix cannot be empty")
+	}
+	if signer == nil {
+		log.Fatal("signer cannot be nil")
+	}
+	return &redisSessionStore{
+		client: client,
+		prefix: prefix,
+		signer: signer,
+	}
+}
+
+func (x *redisSessionStore) Load(cookieValue string) (*SessionData, error) {
+	var id string
+	err := x.signer.Decrypt(_sessionIDCookieName, cookieValue, &id)
+	if err != nil {
+		log.Debugf("invalid session id cookie: %v", err)
+		return nil, nil
+	}
+
+	data, err := x.client.Get(context.Background(), x.prefix+id).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, serr.WithStack(err)
+	}
+
+	r := new(SessionData)
+	err = json.Unmarshal(data, r)
+	if err != nil {
+		// 无法解析的记录按不存在处理，否则每个请求都会当作存储不可用
+		log.Warnf("delete corrupt session '%s': %v", id, err)
+		x.client.Del(context.Background(), x.prefix+id)
+		return nil, nil
+	}
+	return r, nil
+}
+
+func (x *redisSessionStore) Save(data *SessionData, ttl time.Duration) (string, error) {
+	j, err := json.Marshal(data)
+	if err != nil {
+		return "", serr.WithStack(err)
+	}
+	err = x.client.Set(context.Background(), x.prefix+data.ID, j, ttl).Err()
+	if err != nil {
+		return "", serr.WithStack(err)
+	}
+	return x.signer.Encrypt(_sessionIDCookieName, data.ID)
+}
+
+func (x *redisSessionStore) Delete(data *SessionData) error {
+	err := x.client.Del(context.Background(), x.prefix+data.ID).Err()
+	return serr.WithStack(err)
+}
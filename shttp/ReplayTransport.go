@@ -0,0 +1,361 @@
+package shttp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/syncfuture/go/serr"
+)
+
+type ReplayMode int
+
+const (
+	// ReplayModeReplay 只回放，找不到匹配的记录时返回错误，不访问网络
+	ReplayModeReplay ReplayMode = iota
+	// ReplayModeRecord 访问网络并把请求和响应记录到文件
+	ReplayModeRecord
+	// ReplayModeAuto 记录文件存在时回放，否则记录
+	ReplayModeAuto
+)
+
+const (
+	_redacted = "***"
+)
+
+var (
+	// RedactedFields 记录时需要隐藏的查询参数、表单和 JSON 字段
+	RedactedFields = []string{"password", "client_secret", "access_token", "refresh_token", "id_token", "api_key", "apikey", "token", "secret"}
+)
+
+// Exchange 一次记录的请求和响应
+type Exchange struct {
+	Request  *RecordedMessage `json:"request"`
+	Response *RecordedMessage `json:"response"`
+}
+
+// RecordedMessage 记录的请求或响应，文本内容保存在 Body，二进制内容以 base64 保存在 BodyBase64
+type RecordedMessage struct {
+	Method     string      `json:"method,omitempty"`
+	URL        string      `json:"url,omitempty"`
+	StatusCode int         `json:"statusCode,omitempty"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 string      `json:"bodyBase64,omitempty"`
+}
+
+// ReplayMissError 回放时找不到匹配的记录
+type ReplayMissError struct {
+	Method string
+	URL    string
+}
+
+func (x *ReplayMissError) Error() string {
+	return fmt.Sprintf("replay: no recorded exchange matches %s %s", x.Method, x.URL)
+}
+
+// ReplayTransport 记录/回放 http.RoundTripper，用于不访问网络的确定性测试：
+// 记录时把真实的请求和响应保存为 JSON 文件（隐藏 RedactedHeaders 和 RedactedFields），回放时从文件返回响应。
+// 严格匹配要求方法、完整 URL 和请求体一致，且每条记录只使用一次；宽松匹配只比较方法、路径和查询参数，记录可以重复使用
+type ReplayTransport struct {
+	File string
+	Mode ReplayMode
+	// Strict 严格匹配
+	Strict bool
+	// Transport 记录时使用的真实 Transport，默认 http.DefaultTransport
+	Transport http.RoundTripper
+	// Matcher 自定义匹配，不为 nil 时替代默认的匹配规则
+	Matcher func(request *RecordedMessage, recorded *Exchange) bool
+
+	lock      sync.Mutex
+	loaded    bool
+	recording bool
+	exchanges []*Exchange
+	used      []bool
+}
+
+func NewReplayTransport(file string, mode ReplayMode) *ReplayTransport {
+	return &ReplayTransport{
+		File: file,
+		Mode: mode,
+	}
+}
+
+// Client 返回使用这个 Transport 的 http.Client
+func (x *ReplayTransport) Client() *http.Client {
+	return &http.Client{Transport: x}
+}
+
+func (x *ReplayTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	err := x.load()
+	if err != nil {
+		return nil, err
+	}
+
+	recorded, send, err := recordRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if x.recording {
+		return x.record(send, recorded)
+	}
+	return x.replay(request, recorded)
+}
+
+func (x *ReplayTransport) load() error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.loaded {
+		return nil
+	}
+
+	data, err := os.ReadFile(x.File)
+	switch {
+	case err == nil && x.Mode != ReplayModeRecord:
+		err = json.Unmarshal(data, &x.exchanges)
+		if err != nil {
+			return serr.Wrapf(err, "replay: invalid file %s", x.File)
+		}
+		x.used = make([]bool, len(x.exchanges))
+	case x.Mode == ReplayModeRecord || (os.IsNotExist(err) && x.Mode == ReplayModeAuto):
+		x.recording = true
+		x.exchanges = nil
+	default:
+		return serr.WithStack(err)
+	}
+
+	x.loaded = true
+	return nil
+}
+
+func (x *ReplayTransport) replay(request *http.Request, recorded *RecordedMessage) (*http.Response, error) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	for i, e := range x.exchanges {
+		if x.Strict && x.used[i] {
+			continue
+		}
+		if x.match(recorded, e) {
+			x.used[i] = true
+			return e.Response.toResponse(request)
+		}
+	}
+	return nil, &ReplayMissError{Method: recorded.Method, URL: recorded.URL}
+}
+
+func (x *ReplayTransport) match(request *RecordedMessage, e *Exchange) bool {
+	if x.Matcher != nil {
+		return x.Matcher(request, e)
+	}
+	if request.Method != e.Request.Method {
+		return false
+	}
+	if x.Strict {
+		return request.URL == e.Request.URL && request.Body == e.Request.Body && request.BodyBase64 == e.Request.BodyBase64
+	}
+
+	a, err1 := url.Parse(request.URL)
+	b, err2 := url.Parse(e.Request.URL)
+	if err1 != nil || err2 != nil {
+		return request.URL == e.Request.URL
+	}
+	return a.Host == b.Host && a.Path == b.Path && a.Query().Encode() == b.Query().Encode()
+}
+
+func (x *ReplayTransport) record(request *http.Request, recorded *RecordedMessage) (*http.Response, error) {
+	transport := x.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	resp, err := transport.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, serr.WithStack(err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	response := &RecordedMessage{
+		StatusCode: resp.StatusCode,
+		Header:     RedactHeaders(resp.Header),
+	}
+	response.setBody(redactBody(body, resp.Header.Get(HEADER_CTYPE)))
+
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.exchanges = append(x.exchanges, &Exchange{Request: recorded, Response: response})
+	if err = x.save(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (x *ReplayTransport) save() error {
+	data, err := json.MarshalIndent(x.exchanges, "", "  ")
+	if err != nil {
+		return serr.WithStack(err)
+	}
+	err = os.MkdirAll(filepath.Dir(x.File), 0755)
+	if err == nil {
+		err = os.WriteFile(x.File, data, 0644)
+	}
+	return serr.WithStack(err)
+}
+
+// recordRequest 生成隐藏了敏感信息的记录，同时返回用于发送的请求。RoundTrip 不能修改调用者的请求，
+// 有 GetBody 时从它读取请求体，原请求直接发送，否则读取原请求体，发送带有新请求体的副本
+func recordRequest(request *http.Request) (*RecordedMessage, *http.Request, error) {
+	var body []byte
+	send := request
+	if request.Body != nil && request.Body != http.NoBody {
+		var err error
+		if request.GetBody != nil {
+			var rc io.ReadCloser
+			rc, err = request.GetBody()
+			if err == nil {
+				body, err = io.ReadAll(rc)
+				rc.Close()
+			}
+		} else {
+			body, err = io.ReadAll(request.Body)
+			request.Body.Close()
+			if err == nil {
+				send = request.Clone(request.Context())
+				send.Body = io.NopCloser(bytes.NewReader(body))
+			}
+		}
+		if err != nil {
+			return nil, nil, serr.WithStack(err)
+		}
+	}
+
+	u := *request.URL
+	u.RawQuery = redactValues(u.Query()).Encode()
+	r := &RecordedMessage{
+		Method: request.Method,
+		URL:    u.String(),
+		Header: RedactHeaders(request.Header),
+	}
+	r.setBody(redactBody(body, request.Header.Get(HEADER_CTYPE)))
+	return r, send, nil
+}
+
+func (x *RecordedMessage) setBody(body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	if utf8.Valid(body) {
+		x.Body = string(body)
+	} else {
+		x.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+	}
+}
+
+func (x *RecordedMessage) toResponse(request *http.Request) (*http.Response, error) {
+	body := []byte(x.Body)
+	if x.BodyBase64 != "" {
+		var err error
+		body, err = base64.StdEncoding.DecodeString(x.BodyBase64)
+		if err != nil {
+			return nil, serr.WithStack(err)
+		}
+	}
+
+	header := x.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	statusCode := x.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(statusCode) + " " + http.StatusText(statusCode),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       request,
+	}, nil
+}
+
+func isRedactedField(name string) bool {
+	for _, f := range RedactedFields {
+		if strings.EqualFold(f, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactValues(values url.Values) url.Values {
+	for k, v := range values {
+		if isRedactedField(k) {
+			for i := range v {
+				v[i] = _redacted
+			}
+		}
+	}
+	return values
+}
+
+// redactBody 隐藏表单和 JSON 中的敏感字段，其他内容原样返回
+func redactBody(body []byte, contentType string) []byte {
+	if len(body) == 0 {
+		return body
+	}
+
+	switch {
+	case strings.HasPrefix(contentType, CTYPE_FORM):
+		values, err := url.ParseQuery(string(body))
+		if err == nil {
+			return []byte(redactValues(values).Encode())
+		}
+	case strings.Contains(contentType, "json"):
+		var v interface{}
+		if json.Unmarshal(body, &v) == nil {
+			if data, err := json.Marshal(redactJSON(v)); err == nil {
+				return data
+			}
+		}
+	}
+	return body
+}
+
+func redactJSON(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if isRedactedField(k) {
+				t[k] = _redacted
+			} else {
+				t[k] = redactJSON(e)
+			}
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = redactJSON(e)
+		}
+	}
+	return v
+}
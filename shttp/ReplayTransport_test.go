@@ -0,0 +1,92 @@
+package shttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/syncfuture/go/serr"
+)
+
+func TestReplayTransport(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set(HEADER_CTYPE, CTYPE_JSON)
+		w.Write([]byte(`{"access_token":"secret-token","name":"` + r.URL.Query().Get("name") + `"}`))
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "golden.json")
+	apiClient := new(APIClient)
+	ctx := context.Background()
+	configRequest := func(r *http.Request) { r.Header.Set(HEADER_AUTH, "Bearer abc") }
+
+	// 记录
+	recorder := NewReplayTransport(file, ReplayModeAuto)
+	buffer, err := apiClient.DoBufferContext(ctx, recorder.Client(), http.MethodPost, server.URL+"/a?name=x&api_key=k", configRequest, map[string]string{"password": "p", "user": "u"})
+	assert.NoError(t, err)
+	assert.Contains(t, buffer.String(), "secret-token")
+	RecycleBuffer(buffer)
+	assert.Equal(t, 1, calls)
+
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	golden := string(data)
+	for _, secret := range []string{"secret-token", "Bearer abc", `"p"`, "api_key=k"} {
+		assert.False(t, strings.Contains(golden, secret), secret)
+	}
+
+	// 宽松回放：查询参数顺序和请求体可以不同，记录可以重复使用
+	player := NewReplayTransport(file, ReplayModeAuto)
+	for i := 0; i < 2; i++ {
+		buffer, err = apiClient.DoBufferContext(ctx, player.Client(), http.MethodPost, server.URL+"/a?api_key=other&name=x", configRequest, map[string]string{"user": "other"})
+		assert.NoError(t, err)
+		assert.Equal(t, `{"access_token":"***","name":"x"}`, buffer.String())
+		RecycleBuffer(buffer)
+	}
+	assert.Equal(t, 1, calls)
+
+	_, err = apiClient.DoContext(ctx, player.Client(), http.MethodGet, server.URL+"/a?name=x", nil, nil)
+	var miss *ReplayMissError
+	assert.True(t, serr.As(err, &miss))
+
+	// 严格回放：请求体必须一致，每条记录只使用一次
+	strict := NewReplayTransport(file, ReplayModeReplay)
+	strict.Strict = true
+	_, err = apiClient.DoContext(ctx, strict.Client(), http.MethodPost, server.URL+"/a?name=x&api_key=k", nil, map[string]string{"password": "p", "user": "other"})
+	assert.True(t, serr.As(err, &miss))
+	resp, err := apiClient.DoContext(ctx, strict.Client(), http.MethodPost, server.URL+"/a?name=x&api_key=k", nil, map[string]string{"password": "changed", "user": "u"})
+	assert.NoError(t, err)
+	resp.Body.Close()
+	_, err = apiClient.DoContext(ctx, strict.Client(), http.MethodPost, server.URL+"/a?name=x&api_key=k", nil, map[string]string{"password": "p", "user": "u"})
+	assert.True(t, serr.As(err, &miss))
+	assert.Equal(t, 1, calls)
+
+	// 只回放模式下文件不存在时返回错误
+	_, err = apiClient.DoContext(ctx, NewReplayTransport(filepath.Join(t.TempDir(), "missing.json"), ReplayModeReplay).Client(), http.MethodGet, server.URL, nil, nil)
+	assert.Error(t, err)
+
+	// 记录时不修改调用者的请求，保存失败时不返回响应
+	body := io.NopCloser(strings.NewReader("raw"))
+	request, _ := http.NewRequest(http.MethodPost, server.URL+"/b", body)
+	request.Header.Set(HEADER_CTYPE, CTYPE_TEXT)
+	recorder = NewReplayTransport(filepath.Join(t.TempDir(), "c.json"), ReplayModeRecord)
+	resp, err = recorder.RoundTrip(request)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, body, request.Body)
+
+	blocker := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(blocker, nil, 0644)
+	request, _ = http.NewRequest(http.MethodGet, server.URL+"/b", nil)
+	resp, err = NewReplayTransport(filepath.Join(blocker, "d.json"), ReplayModeRecord).RoundTrip(request)
+	assert.Error(t, err)
+	assert.Nil(t, resp)
+}
@@ -0,0 +1,333 @@
+package shttp
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/syncfuture/go/serr"
+)
+
+const (
+	HEADER_CACHE_CONTROL     = "Cache-Control"
+	HEADER_EXPIRES           = "Expires"
+	HEADER_AGE               = "Age"
+	HEADER_VARY              = "Vary"
+	HEADER_IF_NONE_MATCH     = "If-None-Match"
+	HEADER_IF_MODIFIED_SINCE = "If-Modified-Since"
+	HEADER_X_CACHE           = "X-Cache"
+)
+
+// ICacheStore 响应缓存的存储
+type ICacheStore interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, entry *CachedResponse, ttl time.Duration)
+	Delete(key string)
+}
+
+// CachedResponse 缓存的响应
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Expires 新鲜期截止时间，之后需要用 ETag/Last-Modified 重新验证
+	Expires time.Time
+}
+
+// CacheStats 缓存命中统计，用于监控
+type CacheStats struct {
+	Hits        uint64
+	Misses      uint64
+	Revalidated uint64
+	Stored      uint64
+}
+
+type ResponseCacheConfig struct {
+	// MaxBodySize 超过这个大小的响应不缓存，默认 1MB
+	MaxBodySize int64
+	// StaleTTL 有 ETag/Last-Modified 的响应过期后继续保存多久用于重新验证，默认 1 小时
+	StaleTTL time.Duration
+}
+
+// ResponseCache 客户端响应缓存，遵循 Cache-Control 的 max-age/no-store/no-cache 和 Expires，
+// 过期后使用 ETag/Last-Modified 重新验证。只缓存 GET 请求，缓存 key 包含 Accept 和响应 Vary 中列出的请求头。
+// 带 Range 的请求不使用缓存，带 Authorization 的请求只缓存明确标记为 public 的响应，并且 key 包含 Authorization，不同用户不会共享缓存。
+// 同一 URL 的非 GET 请求成功后，所有用户和变体的缓存都会失效。APIClient 使用 surl 的 URI key 时按替换前的 URL 缓存，
+// 负载均衡的各个端点共享缓存
+type ResponseCache struct {
+	store       ICacheStore
+	config      ResponseCacheConfig
+	hits        uint64
+	misses      uint64
+	revalidated uint64
+	stored      uint64
+}
+
+func NewResponseCache(store ICacheStore, config *ResponseCacheConfig) *ResponseCache {
+	r := &ResponseCache{store: store}
+	if config != nil {
+		r.config = *config
+	}
+	if r.config.MaxBodySize <= 0 {
+		r.config.MaxBodySize = 1 << 20
+	}
+	if r.config.StaleTTL <= 0 {
+		r.config.StaleTTL = time.Hour
+	}
+	return r
+}
+
+func (x *ResponseCache) Stats() CacheStats {
+	return CacheStats{
+		Hits:        atomic.LoadUint64(&x.hits),
+		Misses:      atomic.LoadUint64(&x.misses),
+		Revalidated: atomic.LoadUint64(&x.revalidated),
+		Stored:      atomic.LoadUint64(&x.stored),
+	}
+}
+
+// Interceptor 以拦截器的方式使用缓存，APIClient.Cache 不为 nil 时会自动使用
+func (x *ResponseCache) Interceptor() Interceptor {
+	return func(request *http.Request, next Invoker) (*http.Response, error) {
+		return x.roundTrip(request, next)
+	}
+}
+
+func (x *ResponseCache) roundTrip(request *http.Request, next Invoker) (*http.Response, error) {
+	urlKey := http.MethodGet + " " + getCacheURL(request)
+	if request.Method != http.MethodGet {
+		resp, err := next(request)
+		if err == nil && request.Method != http.MethodHead && resp.StatusCode < http.StatusBadRequest {
+			// 修改资源的请求成功后删除 URL 的标记，所有用户和变体的缓存都随之失效
+			x.store.Delete(urlKey)
+		}
+		return resp, err
+	}
+
+	reqCC := parseCacheControl(request.Header.Get(HEADER_CACHE_CONTROL))
+	if _, ok := reqCC["no-store"]; ok || request.Header.Get(HEADER_RANGE) != "" {
+		return next(request)
+	}
+
+	// 拦截器在缓存之后执行，查找和保存都使用调用方设置的请求头，不包括拦截器添加的请求头
+	reqHeader := request.Header.Clone()
+
+	// urlKey 保存响应的 Vary 和缓存的版本，实际的响应按版本和请求头的值保存在变体 key 下
+	var entry *CachedResponse
+	found := false
+	if marker, ok := x.store.Get(urlKey); ok {
+		entry, found = x.store.Get(variantKey(urlKey, marker, reqHeader))
+	}
+	if found {
+		_, noCache := reqCC["no-cache"]
+		if !noCache && time.Now().Before(entry.Expires) {
+			atomic.AddUint64(&x.hits, 1)
+			return entry.response(request, "HIT"), nil
+		}
+
+		// 过期，带上验证头重新请求
+		if etag := entry.Header.Get(HEADER_ETAG); etag != "" {
+			request.Header.Set(HEADER_IF_NONE_MATCH, etag)
+		}
+		if lastModified := entry.Header.Get(HEADER_LAST_MODIFIED); lastModified != "" {
+			request.Header.Set(HEADER_IF_MODIFIED_SINCE, lastModified)
+		}
+	}
+
+	resp, err := next(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if found && resp.StatusCode == http.StatusNotModified {
+		drainAndClose(resp.Body)
+		atomic.AddUint64(&x.revalidated, 1)
+		entry = &CachedResponse{
+			StatusCode: entry.StatusCode,
+			Header:     entry.Header.Clone(),
+			Body:       entry.Body,
+			Expires:    entry.Expires,
+		}
+		for k, v := range resp.Header {
+			entry.Header[k] = v
+		}
+		if expires, ttl, ok := x.freshness(reqHeader, entry.Header); ok {
+			entry.Expires = expires
+			x.set(urlKey, reqHeader, entry, ttl)
+		}
+		return entry.response(request, "REVALIDATED"), nil
+	}
+
+	atomic.AddUint64(&x.misses, 1)
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	expires, ttl, ok := x.freshness(reqHeader, resp.Header)
+	if !ok || (resp.ContentLength > x.config.MaxBodySize) {
+		return resp, nil
+	}
+
+	// 读取响应体用于缓存，超过大小限制时原样返回
+	buffer := new(bytes.Buffer)
+	n, err := buffer.ReadFrom(io.LimitReader(resp.Body, x.config.MaxBodySize+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, serr.WithStack(err)
+	}
+	if n > x.config.MaxBodySize {
+		resp.Body = &multiReadCloser{Reader: io.MultiReader(buffer, resp.Body), closer: resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	x.set(urlKey, reqHeader, &CachedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       buffer.Bytes(),
+		Expires:    expires,
+	}, ttl)
+	atomic.AddUint64(&x.stored, 1)
+
+	resp.Body = io.NopCloser(bytes.NewReader(buffer.Bytes()))
+	resp.ContentLength = int64(buffer.Len())
+	return resp, nil
+}
+
+// set 在 urlKey 下保存响应的 Vary 和缓存的版本，在变体 key 下保存响应。
+// 标记的有效期取所有变体中最长的，版本不变，其他变体仍然可以命中
+func (x *ResponseCache) set(urlKey string, reqHeader http.Header, entry *CachedResponse, ttl time.Duration) {
+	expires := time.Now().Add(ttl)
+	marker := &CachedResponse{Header: http.Header{}, Expires: expires}
+	if old, ok := x.store.Get(urlKey); ok && len(old.Body) > 0 {
+		marker.Body = old.Body
+		if old.Expires.After(expires) {
+			marker.Expires = old.Expires
+		}
+	} else {
+		marker.Body = []byte(strconv.FormatInt(time.Now().UnixNano(), 36))
+	}
+	if vary := entry.Header.Values(HEADER_VARY); len(vary) > 0 {
+		marker.Header[HEADER_VARY] = vary
+	}
+	x.store.Set(urlKey, marker, time.Until(marker.Expires))
+	x.store.Set(variantKey(urlKey, marker, reqHeader), entry, ttl)
+}
+
+// freshness 根据响应头计算新鲜期截止时间和存储时间，不能缓存时 ok 为 false
+func (x *ResponseCache) freshness(reqHeader http.Header, header http.Header) (expires time.Time, ttl time.Duration, ok bool) {
+	cc := parseCacheControl(header.Get(HEADER_CACHE_CONTROL))
+	if _, noStore := cc["no-store"]; noStore || varyAll(header) {
+		return time.Time{}, 0, false
+	}
+	if _, public := cc["public"]; !public && reqHeader.Get(HEADER_AUTH) != "" {
+		return time.Time{}, 0, false
+	}
+
+	now := time.Now()
+	var fresh time.Duration
+	if _, noCache := cc["no-cache"]; !noCache {
+		if v, hasMaxAge := cc["max-age"]; hasMaxAge {
+			if seconds, err := strconv.Atoi(v); err == nil {
+				fresh = time.Duration(seconds) * time.Second
+			}
+		} else if v := header.Get(HEADER_EXPIRES); v != "" {
+			if t, err := http.ParseTime(v); err == nil {
+				fresh = t.Sub(now)
+			}
+		}
+		if age, err := strconv.Atoi(header.Get(HEADER_AGE)); err == nil {
+			fresh -= time.Duration(age) * time.Second
+		}
+	}
+	if fresh < 0 {
+		fresh = 0
+	}
+
+	ttl = fresh
+	if header.Get(HEADER_ETAG) != "" || header.Get(HEADER_LAST_MODIFIED) != "" {
+		ttl += x.config.StaleTTL
+	}
+	if ttl <= 0 {
+		return time.Time{}, 0, false
+	}
+	return now.Add(fresh), ttl, true
+}
+
+func (x *CachedResponse) response(request *http.Request, status string) *http.Response {
+	header := x.Header.Clone()
+	header.Set(HEADER_X_CACHE, status)
+	return &http.Response{
+		Status:        strconv.Itoa(x.StatusCode) + " " + http.StatusText(x.StatusCode),
+		StatusCode:    x.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(x.Body)),
+		ContentLength: int64(len(x.Body)),
+		Request:       request,
+	}
+}
+
+// variantKey 在 urlKey 后加上缓存的版本，以及 Authorization、Accept 和 Vary 中列出的请求头的值，
+// 不同用户不会共享缓存
+func variantKey(urlKey string, marker *CachedResponse, reqHeader http.Header) string {
+	names := []string{HEADER_ACCEPT, HEADER_AUTH}
+	for _, v := range marker.Header.Values(HEADER_VARY) {
+		for _, name := range strings.Split(v, ",") {
+			if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" && name != HEADER_ACCEPT && name != HEADER_AUTH {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	h := sha1.New()
+	for _, name := range names {
+		h.Write([]byte(name + ":" + strings.Join(reqHeader.Values(name), ",") + "\n"))
+	}
+	return urlKey + " " + string(marker.Body) + " " + hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+func varyAll(header http.Header) bool {
+	for _, v := range header.Values(HEADER_VARY) {
+		for _, name := range strings.Split(v, ",") {
+			if strings.TrimSpace(name) == "*" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parseCacheControl(v string) map[string]string {
+	r := make(map[string]string)
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if i := strings.Index(part, "="); i >= 0 {
+			r[strings.ToLower(part[:i])] = strings.Trim(part[i+1:], `"`)
+		} else {
+			r[strings.ToLower(part)] = ""
+		}
+	}
+	return r
+}
+
+type multiReadCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (x *multiReadCloser) Close() error {
+	return x.closer.Close()
+}
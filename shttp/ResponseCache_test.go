@@ -0,0 +1,177 @@
+package shttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseCache(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set(HEADER_CACHE_CONTROL, "max-age=60")
+			w.Write([]byte("fresh"))
+		case "/etag":
+			w.Header().Set(HEADER_CACHE_CONTROL, "no-cache")
+			w.Header().Set(HEADER_ETAG, `"v1"`)
+			if r.Header.Get(HEADER_IF_NONE_MATCH) == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Write([]byte("etag"))
+		case "/nostore":
+			w.Header().Set(HEADER_CACHE_CONTROL, "no-store")
+			w.Write([]byte("nostore"))
+		}
+	}))
+	defer server.Close()
+
+	cache := NewResponseCache(NewMemoryCacheStore(10), nil)
+	apiClient := &APIClient{Cache: cache}
+	ctx := context.Background()
+
+	get := func(path string) string {
+		buffer, err := apiClient.DoBufferContext(ctx, http.DefaultClient, "GET", server.URL+path, nil, nil)
+		assert.NoError(t, err)
+		defer RecycleBuffer(buffer)
+		return buffer.String()
+	}
+
+	assert.Equal(t, "fresh", get("/fresh"))
+	assert.Equal(t, "fresh", get("/fresh"))
+	assert.Equal(t, int32(1), calls)
+
+	assert.Equal(t, "etag", get("/etag"))
+	assert.Equal(t, "etag", get("/etag"))
+	assert.Equal(t, int32(3), calls)
+
+	assert.Equal(t, "nostore", get("/nostore"))
+	assert.Equal(t, "nostore", get("/nostore"))
+	assert.Equal(t, int32(5), calls)
+
+	// 修改资源后缓存失效
+	resp, err := apiClient.DoContext(ctx, http.DefaultClient, "PUT", server.URL+"/fresh", nil, "x")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "fresh", get("/fresh"))
+	assert.Equal(t, int32(7), calls)
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Revalidated)
+	assert.Equal(t, uint64(3), stats.Stored)
+	assert.Equal(t, uint64(5), stats.Misses)
+}
+
+func TestResponseCache_Variants(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		switch r.URL.Path {
+		case "/vary":
+			w.Header().Set(HEADER_CACHE_CONTROL, "max-age=60")
+			w.Header().Set(HEADER_VARY, "X-Lang")
+			w.Write([]byte(r.Header.Get(HEADER_ACCEPT) + "|" + r.Header.Get("X-Lang")))
+		case "/private":
+			w.Header().Set(HEADER_CACHE_CONTROL, "max-age=60")
+			w.Write([]byte("private"))
+		case "/public":
+			w.Header().Set(HEADER_CACHE_CONTROL, "public, max-age=60")
+			w.Write([]byte("public"))
+		}
+	}))
+	defer server.Close()
+
+	apiClient := &APIClient{Cache: NewResponseCache(NewMemoryCacheStore(20), nil)}
+	ctx := context.Background()
+	get := func(path string, header map[string]string) string {
+		buffer, err := apiClient.DoBufferContext(ctx, http.DefaultClient, "GET", server.URL+path, func(r *http.Request) {
+			for k, v := range header {
+				r.Header.Set(k, v)
+			}
+		}, nil)
+		assert.NoError(t, err)
+		defer RecycleBuffer(buffer)
+		return buffer.String()
+	}
+
+	// Accept 和 Vary 中的请求头不同时分别缓存
+	assert.Equal(t, CTYPE_JSON+"|en", get("/vary", map[string]string{HEADER_ACCEPT: CTYPE_JSON, "X-Lang": "en"}))
+	assert.Equal(t, CTYPE_PROTOBUF+"|en", get("/vary", map[string]string{HEADER_ACCEPT: CTYPE_PROTOBUF, "X-Lang": "en"}))
+	assert.Equal(t, CTYPE_JSON+"|zh", get("/vary", map[string]string{HEADER_ACCEPT: CTYPE_JSON, "X-Lang": "zh"}))
+	assert.Equal(t, CTYPE_JSON+"|en", get("/vary", map[string]string{HEADER_ACCEPT: CTYPE_JSON, "X-Lang": "en"}))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// Range 请求不使用缓存
+	get("/vary", map[string]string{HEADER_ACCEPT: CTYPE_JSON, "X-Lang": "en", HEADER_RANGE: "bytes=0-1"})
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+
+	// 带 Authorization 时只缓存 public 的响应
+	auth := map[string]string{HEADER_AUTH: "Bearer t1"}
+	get("/private", auth)
+	get("/private", auth)
+	assert.Equal(t, int32(6), atomic.LoadInt32(&calls))
+	get("/public", auth)
+	get("/public", auth)
+	assert.Equal(t, int32(7), atomic.LoadInt32(&calls))
+}
+
+func TestResponseCache_APIClient(t *testing.T) {
+	var calls int32
+	newServer := func() *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.Header().Set(HEADER_CACHE_CONTROL, "public, max-age=60")
+			w.Write([]byte("items"))
+		}))
+	}
+	a, b := newServer(), newServer()
+	defer a.Close()
+	defer b.Close()
+
+	var intercepted int32
+	lb := NewLoadBalancer(&LoadBalancerConfig{Policy: BalanceRoundRobin})
+	lb.SetEndpoints("svc", []string{a.URL, b.URL})
+	apiClient := &APIClient{
+		Cache:        NewResponseCache(NewMemoryCacheStore(20), nil),
+		LoadBalancer: lb,
+		Interceptors: []Interceptor{func(r *http.Request, next Invoker) (*http.Response, error) {
+			atomic.AddInt32(&intercepted, 1)
+			return next(r)
+		}},
+	}
+	ctx := context.Background()
+	get := func(auth string) {
+		buffer, err := apiClient.DoBufferContext(ctx, http.DefaultClient, http.MethodGet, "{{URI 'svc'}}/items", func(r *http.Request) {
+			r.Header.Set(HEADER_AUTH, auth)
+		}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "items", buffer.String())
+		RecycleBuffer(buffer)
+	}
+
+	// 命中时不经过拦截器，各个端点共享缓存
+	get("Bearer u1")
+	get("Bearer u1")
+	get("Bearer u1")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&intercepted))
+
+	// 修改后其他用户的缓存也失效
+	get("Bearer u2")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	resp, err := apiClient.DoContext(ctx, http.DefaultClient, http.MethodPut, "{{URI 'svc'}}/items", func(r *http.Request) {
+		r.Header.Set(HEADER_AUTH, "Bearer u1")
+	}, "x")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	get("Bearer u2")
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+}
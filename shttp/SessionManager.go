@@ -0,0 +1,342 @@
+package shttp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/syncfuture/go/serr"
+	log "github.com/syncfuture/go/slog"
+	"github.com/syncfuture/go/spool"
+)
+
+type sessionKey struct{}
+
+// ISessionStore 会话的存储
+type ISessionStore interface {
+	// Load 根据 cookie 的值加载会话，不存在或者无效时返回 nil
+	Load(cookieValue string) (*SessionData, error)
+	// Save 保存会话并返回写入 cookie 的值
+	Save(data *SessionData, ttl time.Duration) (cookieValue string, err error)
+	Delete(data *SessionData) error
+}
+
+// SessionData 会话中保存的数据，CreatedAt 和 AccessedAt 为 Unix 秒
+type SessionData struct {
+	ID         string
+	Values     map[string]string
+	Flashes    map[string][]string
+	CreatedAt  int64
+	AccessedAt int64
+}
+
+type SessionConfig struct {
+	// CookieName 默认 "sid"
+	CookieName string
+	// Path 默认 "/"
+	Path     string
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+	// IdleTimeout 超过这个时间没有访问的会话失效，默认 30 分钟
+	IdleTimeout time.Duration
+	// AbsoluteTimeout 会话创建后最长的有效时间，默认 24 小时
+	AbsoluteTimeout time.Duration
+}
+
+// SessionManager 会话管理，Middleware 在请求开始时加载会话，在写入响应头之前保存修改并更新 cookie。
+// 同一个会话的并发请求各自加载和保存，后保存的覆盖先保存的
+type SessionManager struct {
+	store      ISessionStore
+	config     SessionConfig
+	cookiePool spool.ICookiePool
+}
+
+func NewSessionManager(store ISessionStore, config *SessionConfig) *SessionManager {
+	r := &SessionManager{
+		store:      store,
+		cookiePool: spool.NewSyncCookiePool(),
+	}
+	if config != nil {
+		r.config = *config
+	}
+	if r.config.CookieName == "" {
+		r.config.CookieName = "sid"
+	}
+	if r.config.Path == "" {
+		r.config.Path = "/"
+	}
+	if r.config.SameSite == 0 {
+		r.config.SameSite = http.SameSiteLaxMode
+	}
+	if r.config.IdleTimeout <= 0 {
+		r.config.IdleTimeout = 30 * time.Minute
+	}
+	if r.config.AbsoluteTimeout <= 0 {
+		r.config.AbsoluteTimeout = 24 * time.Hour
+	}
+	return r
+}
+
+// GetSession 获取 SessionManager.Middleware 加载的会话，没有使用中间件时返回 nil
+func GetSession(ctx context.Context) *Session {
+	session, _ := ctx.Value(sessionKey{}).(*Session)
+	return session
+}
+
+func (x *SessionManager) Middleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session := x.load(r)
+			sw := &sessionWriter{ResponseWriter: w, manager: x, session: session}
+			next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), sessionKey{}, session)))
+			sw.commit()
+		})
+	}
+}
+
+func (x *SessionManager) load(r *http.Request) *Session {
+	cookie, err := r.Cookie(x.config.CookieName)
+	hasCookie := err == nil && cookie.Value != ""
+	if hasCookie {
+		data, err := x.store.Load(cookie.Value)
+		if err != nil {
+			// 存储暂时不可用时不能当作会话过期，否则会清除所有用户的 cookie，
+			// 使用不保存的临时会话，保留原来的 cookie
+			log.Errorf("load session failed: %+v", err)
+			return &Session{data: newSessionData(), unavailable: true}
+		}
+		if data != nil {
+			now := time.Now()
+			if now.Sub(time.Unix(data.AccessedAt, 0)) <= x.config.IdleTimeout && now.Sub(time.Unix(data.CreatedAt, 0)) <= x.config.AbsoluteTimeout {
+				return &Session{data: data, loaded: true}
+			}
+			// 过期的会话删除后重新创建
+			if err = x.store.Delete(data); err != nil {
+				log.Warnf("delete expired session failed: %v", err)
+			}
+		}
+	}
+	// 带有无效或过期 cookie 的请求，没有写入新会话时清除 cookie
+	return &Session{data: newSessionData(), expired: hasCookie}
+}
+
+// save 保存会话并设置 cookie，会话没有修改时只在距离上次访问超过 IdleTimeout 的十分之一时更新访问时间
+func (x *SessionManager) save(w http.ResponseWriter, session *Session) {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+
+	if session.destroyed {
+		for _, data := range []*SessionData{session.oldData, session.data} {
+			if data == nil || (data == session.data && !session.loaded) {
+				continue
+			}
+			if err := x.store.Delete(data); err != nil {
+				log.Warnf("delete session failed: %v", err)
+			}
+		}
+		x.setCookie(w, "", -1)
+		return
+	}
+
+	if session.unavailable {
+		return
+	}
+
+	now := time.Now()
+	touch := session.loaded && now.Sub(time.Unix(session.data.AccessedAt, 0)) > x.config.IdleTimeout/10
+	if !session.dirty && !touch {
+		if session.expired && !session.loaded {
+			x.setCookie(w, "", -1)
+		}
+		return
+	}
+
+	if session.oldData != nil {
+		if err := x.store.Delete(session.oldData); err != nil {
+			log.Warnf("delete rotated session failed: %v", err)
+		}
+	}
+
+	session.data.AccessedAt = now.Unix()
+	ttl := x.config.IdleTimeout
+	if left := time.Unix(session.data.CreatedAt, 0).Add(x.config.AbsoluteTimeout).Sub(now); left < ttl {
+		ttl = left
+	}
+	value, err := x.store.Save(session.data, ttl)
+	if err != nil {
+		log.Errorf("save session failed: %+v", err)
+		return
+	}
+	x.setCookie(w, value, int(ttl/time.Second))
+}
+
+func (x *SessionManager) setCookie(w http.ResponseWriter, value string, maxAge int) {
+	cookie := x.cookiePool.GetCookie()
+	defer x.cookiePool.PutCookie(cookie)
+
+	cookie.Name = x.config.CookieName
+	cookie.Value = value
+	cookie.Path = x.config.Path
+	cookie.Domain = x.config.Domain
+	cookie.Secure = x.config.Secure
+	cookie.HttpOnly = true
+	cookie.SameSite = x.config.SameSite
+	cookie.MaxAge = maxAge
+	http.SetCookie(w, cookie)
+}
+
+// sessionWriter 第一次写入响应头之前保存会话，使 Set-Cookie 能够发出
+type sessionWriter struct {
+	http.ResponseWriter
+	manager *SessionManager
+	session *Session
+	once    sync.Once
+}
+
+func (x *sessionWriter) commit() {
+	x.once.Do(func() {
+		x.manager.save(x.ResponseWriter, x.session)
+	})
+}
+
+func (x *sessionWriter) WriteHeader(statusCode int) {
+	x.commit()
+	x.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (x *sessionWriter) Write(p []byte) (int, error) {
+	x.commit()
+	return x.ResponseWriter.Write(p)
+}
+
+func (x *sessionWriter) Flush() {
+	x.commit()
+	if f, ok := x.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Session 当前请求的会话，方法可以并发调用
+type Session struct {
+	lock      sync.RWMutex
+	data      *SessionData
+	oldData   *SessionData
+	loaded    bool
+	expired   bool
+	dirty     bool
+	destroyed bool
+	// unavailable 读取存储失败，会话是临时的
+	unavailable bool
+}
+
+func newSessionData() *SessionData {
+	now := time.Now().Unix()
+	return &SessionData{
+		ID:         newSessionID(),
+		Values:     make(map[string]string),
+		CreatedAt:  now,
+		AccessedAt: now,
+	}
+}
+
+func newSessionID() string {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(serr.WithStack(err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (x *Session) ID() string {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	return x.data.ID
+}
+
+// IsNew 会话是否在这次请求中创建
+func (x *Session) IsNew() bool {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	return !x.loaded
+}
+
+// Unavailable 会话存储读取失败时为 true，此时会话是临时的，修改不会保存
+func (x *Session) Unavailable() bool {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	return x.unavailable
+}
+
+func (x *Session) Get(key string) (string, bool) {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	v, ok := x.data.Values[key]
+	return v, ok
+}
+
+func (x *Session) Set(key, value string) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.data.Values == nil {
+		x.data.Values = make(map[string]string)
+	}
+	x.data.Values[key] = value
+	x.dirty = true
+}
+
+func (x *Session) Delete(key string) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if _, ok := x.data.Values[key]; ok {
+		delete(x.data.Values, key)
+		x.dirty = true
+	}
+}
+
+// AddFlash 添加一次性消息，在下一次调用 Flashes 时取出
+func (x *Session) AddFlash(category, message string) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.data.Flashes == nil {
+		x.data.Flashes = make(map[string][]string)
+	}
+	x.data.Flashes[category] = append(x.data.Flashes[category], message)
+	x.dirty = true
+}
+
+// Flashes 取出并删除一次性消息
+func (x *Session) Flashes(category string) []string {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	r, ok := x.data.Flashes[category]
+	if ok {
+		delete(x.data.Flashes, category)
+		x.dirty = true
+	}
+	return r
+}
+
+// Rotate 更换会话 ID 并保留数据，登录等权限变化时调用以防止会话固定攻击，
+// CreatedAt 保持不变，更换 ID 不会延长绝对超时
+func (x *Session) Rotate() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.loaded && x.oldData == nil {
+		old := *x.data
+		x.oldData = &old
+	}
+	x.data.ID = newSessionID()
+	x.dirty = true
+}
+
+// Destroy 删除会话和 cookie，用于退出登录
+func (x *Session) Destroy() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.destroyed = true
+}
@@ -0,0 +1,197 @@
+package shttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/stretchr/testify/assert"
+	"github.com/syncfuture/go/serr"
+	"github.com/syncfuture/go/ssecurity"
+)
+
+// testSessionStore 内存中的会话存储，cookie 值即会话 ID
+type testSessionStore struct {
+	lock sync.Mutex
+	data map[string]SessionData
+	// failing 为 true 时模拟存储不可用
+	failing bool
+}
+
+func (x *testSessionStore) Load(cookieValue string) (*SessionData, error) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.failing {
+		return nil, serr.New("connection refused")
+	}
+	if d, ok := x.data[cookieValue]; ok {
+		return &d, nil
+	}
+	return nil, nil
+}
+
+func (x *testSessionStore) Save(data *SessionData, ttl time.Duration) (string, error) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.data[data.ID] = *data
+	return data.ID, nil
+}
+
+func (x *testSessionStore) Delete(data *SessionData) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	delete(x.data, data.ID)
+	return nil
+}
+
+func serveSession(handler http.Handler, cookie *http.Cookie) *http.Cookie {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	cookies := w.Result().Cookies()
+	if len(cookies) == 0 {
+		return nil
+	}
+	return cookies[0]
+}
+
+func TestSessionManager(t *testing.T) {
+	store := &testSessionStore{data: make(map[string]SessionData)}
+	manager := NewSessionManager(store, &SessionConfig{IdleTimeout: time.Hour})
+
+	var action func(s *Session)
+	handler := manager.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		action(GetSession(r.Context()))
+		w.Write([]byte("ok"))
+	}))
+
+	// 没有修改的新会话不设置 cookie
+	action = func(s *Session) { assert.True(t, s.IsNew()) }
+	assert.Nil(t, serveSession(handler, nil))
+
+	action = func(s *Session) {
+		s.Set("user", "1")
+		s.AddFlash("info", "saved")
+	}
+	cookie := serveSession(handler, nil)
+	assert.NotNil(t, cookie)
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, 3600, cookie.MaxAge)
+
+	action = func(s *Session) {
+		assert.False(t, s.IsNew())
+		v, _ := s.Get("user")
+		assert.Equal(t, "1", v)
+		assert.Equal(t, []string{"saved"}, s.Flashes("info"))
+	}
+	cookie = serveSession(handler, cookie)
+
+	// flash 只能读取一次
+	action = func(s *Session) { assert.Empty(t, s.Flashes("info")) }
+	serveSession(handler, cookie)
+
+	// 登录时更换 ID，旧 ID 失效，创建时间不变
+	var oldID string
+	action = func(s *Session) {
+		oldID = s.ID()
+		s.Rotate()
+	}
+	createdAt := time.Now().Add(-time.Minute).Unix()
+	for id, d := range store.data {
+		d.CreatedAt = createdAt
+		store.data[id] = d
+	}
+	rotated := serveSession(handler, cookie)
+	assert.NotEqual(t, oldID, rotated.Value)
+	_, exists := store.data[oldID]
+	assert.False(t, exists)
+	assert.Equal(t, createdAt, store.data[rotated.Value].CreatedAt)
+	action = func(s *Session) {
+		v, _ := s.Get("user")
+		assert.Equal(t, "1", v)
+	}
+	serveSession(handler, rotated)
+
+	// 空闲超时
+	d := store.data[rotated.Value]
+	d.AccessedAt = time.Now().Add(-2 * time.Hour).Unix()
+	store.data[rotated.Value] = d
+	action = func(s *Session) { assert.True(t, s.IsNew()) }
+	cleared := serveSession(handler, rotated)
+	assert.Equal(t, -1, cleared.MaxAge)
+	assert.Empty(t, store.data)
+
+	// 退出登录
+	action = func(s *Session) { s.Set("user", "2") }
+	cookie = serveSession(handler, nil)
+	action = func(s *Session) { s.Destroy() }
+	cleared = serveSession(handler, cookie)
+	assert.Equal(t, -1, cleared.MaxAge)
+	assert.Empty(t, store.data)
+}
+
+func TestSessionManager_StoreFailure(t *testing.T) {
+	store := &testSessionStore{data: make(map[string]SessionData)}
+	manager := NewSessionManager(store, &SessionConfig{IdleTimeout: time.Hour})
+
+	var action func(s *Session)
+	handler := manager.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		action(GetSession(r.Context()))
+		w.Write([]byte("ok"))
+	}))
+
+	action = func(s *Session) { s.Set("user", "1") }
+	cookie := serveSession(handler, nil)
+
+	// 存储不可用时使用临时会话，不保存也不清除 cookie
+	store.failing = true
+	action = func(s *Session) {
+		assert.True(t, s.Unavailable())
+		_, ok := s.Get("user")
+		assert.False(t, ok)
+		s.Set("user", "2")
+	}
+	assert.Nil(t, serveSession(handler, cookie))
+
+	// 存储恢复后原来的会话仍然有效
+	store.failing = false
+	action = func(s *Session) {
+		assert.False(t, s.Unavailable())
+		v, _ := s.Get("user")
+		assert.Equal(t, "1", v)
+	}
+	serveSession(handler, cookie)
+}
+
+func TestCookieSessionStore(t *testing.T) {
+	encryptor := ssecurity.NewSecureCookieEncryptor(securecookie.New(securecookie.GenerateRandomKey(32), securecookie.GenerateRandomKey(32)))
+	manager := NewSessionManager(NewCookieSessionStore("sid", encryptor), nil)
+
+	var action func(s *Session)
+	handler := manager.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		action(GetSession(r.Context()))
+	}))
+
+	action = func(s *Session) { s.Set("user", "1") }
+	cookie := serveSession(handler, nil)
+	assert.NotContains(t, cookie.Value, "user")
+
+	action = func(s *Session) {
+		v, ok := s.Get("user")
+		assert.True(t, ok)
+		assert.Equal(t, "1", v)
+	}
+	serveSession(handler, cookie)
+
+	// 被篡改的 cookie 按新会话处理
+	cookie.Value = cookie.Value[:len(cookie.Value)-2] + "xx"
+	action = func(s *Session) { assert.True(t, s.IsNew()) }
+	cleared := serveSession(handler, cookie)
+	assert.Equal(t, -1, cleared.MaxAge)
+}
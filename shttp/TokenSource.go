@@ -0,0 +1,261 @@
+package shttp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syncfuture/go/serr"
+)
+
+const (
+	GRANT_CLIENT_CREDENTIALS = "client_credentials"
+	GRANT_REFRESH_TOKEN      = "refresh_token"
+)
+
+// Token OAuth2 令牌
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	// Expiry 根据 ExpiresIn 计算的过期时间，为零值表示不过期
+	Expiry time.Time `json:"-"`
+}
+
+// Type 返回 Authorization 头使用的类型，默认 Bearer
+func (x *Token) Type() string {
+	if x.TokenType == "" || strings.EqualFold(x.TokenType, "bearer") {
+		return "Bearer"
+	}
+	return x.TokenType
+}
+
+func (x *Token) valid(delta time.Duration) bool {
+	if x == nil || x.AccessToken == "" {
+		return false
+	}
+	return x.Expiry.IsZero() || time.Now().Add(delta).Before(x.Expiry)
+}
+
+type ITokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+type TokenSourceConfig struct {
+	// TokenURL 令牌端点，例如 https://passport.example.com/connect/token
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// EndpointParams 额外的表单参数，例如 audience
+	EndpointParams url.Values
+	// AuthInParams 为 true 时 client_id/client_secret 放在表单中，否则使用 Basic 认证
+	AuthInParams bool
+	// ExpiryDelta 提前多久刷新令牌，默认 30 秒
+	ExpiryDelta time.Duration
+	// HTTPClient 请求令牌使用的客户端，默认 http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// TokenError 令牌端点返回的 OAuth2 错误
+type TokenError struct {
+	StatusCode  int
+	ErrorCode   string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (x *TokenError) Error() string {
+	return fmt.Sprintf("oauth2: token request failed [%d] %s: %s", x.StatusCode, x.ErrorCode, x.Description)
+}
+
+func (x *TokenError) Code() string {
+	return x.ErrorCode
+}
+
+// Retryable 令牌端点的 5xx 和 429 可以重试
+func (x *TokenError) Retryable() bool {
+	return x.StatusCode >= http.StatusInternalServerError || x.StatusCode == http.StatusTooManyRequests
+}
+
+type tokenSource struct {
+	config    TokenSourceConfig
+	grantType string
+	lock      sync.Mutex
+	token     *Token
+	inflight  *tokenCall
+}
+
+// tokenCall 同一时间只发出一个令牌请求，其他调用方等待它的结果
+type tokenCall struct {
+	done  chan struct{}
+	token *Token
+	err   error
+}
+
+// NewClientCredentialsTokenSource 使用 client_credentials 授权获取令牌，令牌在过期前缓存，
+// 令牌端点返回 refresh_token 时优先用它刷新
+func NewClientCredentialsTokenSource(config *TokenSourceConfig) ITokenSource {
+	return newTokenSource(config, GRANT_CLIENT_CREDENTIALS, nil)
+}
+
+// NewRefreshTokenSource 使用 refresh_token 授权刷新令牌，令牌端点返回新的 refresh_token 时会替换旧的
+func NewRefreshTokenSource(config *TokenSourceConfig, refreshToken string) ITokenSource {
+	return newTokenSource(config, GRANT_REFRESH_TOKEN, &Token{RefreshToken: refreshToken})
+}
+
+func newTokenSource(config *TokenSourceConfig, grantType string, token *Token) *tokenSource {
+	r := &tokenSource{
+		config:    *config,
+		grantType: grantType,
+		token:     token,
+	}
+	if r.config.ExpiryDelta <= 0 {
+		r.config.ExpiryDelta = 30 * time.Second
+	}
+	if r.config.HTTPClient == nil {
+		r.config.HTTPClient = http.DefaultClient
+	}
+	return r
+}
+
+func (x *tokenSource) Token(ctx context.Context) (*Token, error) {
+	x.lock.Lock()
+	if x.token.valid(x.config.ExpiryDelta) {
+		r := x.token
+		x.lock.Unlock()
+		return r, nil
+	}
+
+	call := x.inflight
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		x.inflight = call
+		current := x.token
+		x.lock.Unlock()
+
+		// 使用独立的 context，避免第一个调用方取消时其他等待的调用方也失败
+		go x.refresh(call, current)
+	} else {
+		x.lock.Unlock()
+	}
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return nil, serr.WithStack(ctx.Err())
+	}
+}
+
+func (x *tokenSource) refresh(call *tokenCall, current *Token) {
+	defer close(call.done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var token *Token
+	var err error
+	if current != nil && current.RefreshToken != "" {
+		token, err = x.fetch(ctx, url.Values{
+			"grant_type":    {GRANT_REFRESH_TOKEN},
+			"refresh_token": {current.RefreshToken},
+		})
+		if err == nil && token.RefreshToken == "" {
+			token.RefreshToken = current.RefreshToken
+		}
+	}
+	if x.grantType == GRANT_CLIENT_CREDENTIALS && (token == nil || err != nil) {
+		params := url.Values{"grant_type": {GRANT_CLIENT_CREDENTIALS}}
+		if len(x.config.Scopes) > 0 {
+			params.Set("scope", strings.Join(x.config.Scopes, " "))
+		}
+		token, err = x.fetch(ctx, params)
+	}
+	if token == nil && err == nil {
+		err = serr.New("oauth2: no refresh token available")
+	}
+
+	x.lock.Lock()
+	if err == nil {
+		x.token = token
+	}
+	x.inflight = nil
+	x.lock.Unlock()
+
+	call.token, call.err = token, err
+}
+
+func (x *tokenSource) fetch(ctx context.Context, params url.Values) (*Token, error) {
+	for k, v := range x.config.EndpointParams {
+		params[k] = v
+	}
+	if x.config.AuthInParams {
+		params.Set("client_id", x.config.ClientID)
+		if x.config.ClientSecret != "" {
+			params.Set("client_secret", x.config.ClientSecret)
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, x.config.TokenURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, serr.WithStack(err)
+	}
+	request.Header.Set(HEADER_CTYPE, CTYPE_FORM)
+	request.Header.Set(HEADER_ACCEPT, CTYPE_JSON)
+	if !x.config.AuthInParams {
+		request.SetBasicAuth(url.QueryEscape(x.config.ClientID), url.QueryEscape(x.config.ClientSecret))
+	}
+
+	resp, err := x.config.HTTPClient.Do(request)
+	if err != nil {
+		return nil, serr.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	buffer := _bufferPool.GetBuffer()
+	defer _bufferPool.PutBuffer(buffer)
+	_, err = buffer.ReadFrom(resp.Body)
+	if err != nil {
+		return nil, serr.WithStack(err)
+	}
+
+	if !IsSuccessStatus(resp.StatusCode) {
+		tokenErr := &TokenError{StatusCode: resp.StatusCode}
+		if json.Unmarshal(buffer.Bytes(), tokenErr) != nil || tokenErr.ErrorCode == "" {
+			tokenErr.ErrorCode = "server_error"
+			tokenErr.Description = buffer.String()
+		}
+		return nil, serr.WithStack(tokenErr)
+	}
+
+	token := new(Token)
+	err = json.Unmarshal(buffer.Bytes(), token)
+	if err != nil {
+		return nil, serr.Wrap(err, "oauth2: invalid token response")
+	}
+	if token.AccessToken == "" {
+		return nil, serr.New("oauth2: token response has no access_token")
+	}
+	if token.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// TokenInterceptor 使用 ts 的令牌设置 Authorization 头
+func TokenInterceptor(ts ITokenSource) Interceptor {
+	return AuthInterceptor(func(ctx context.Context) (string, error) {
+		token, err := ts.Token(ctx)
+		if err != nil {
+			return "", err
+		}
+		return token.Type() + " " + token.AccessToken, nil
+	})
+}
@@ -0,0 +1,98 @@
+package shttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/syncfuture/go/serr"
+)
+
+func TestClientCredentialsTokenSource(t *testing.T) {
+	var issued int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		r.ParseForm()
+		w.Header().Set(HEADER_CTYPE, CTYPE_JSON)
+		if id != "client" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client","error_description":"bad secret"}`))
+			return
+		}
+		n := atomic.AddInt32(&issued, 1)
+		fmt.Fprintf(w, `{"access_token":"token%d","token_type":"bearer","expires_in":3600,"scope":"%s"}`, n, r.Form.Get("scope"))
+	}))
+	defer tokenServer.Close()
+
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get(HEADER_AUTH)))
+	}))
+	defer apiServer.Close()
+
+	ts := NewClientCredentialsTokenSource(&TokenSourceConfig{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Scopes:       []string{"api"},
+	})
+
+	// 并发获取只请求一次令牌
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := ts.Token(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, "token1", token.AccessToken)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&issued))
+
+	apiClient := &APIClient{Interceptors: []Interceptor{TokenInterceptor(ts)}}
+	buffer, err := apiClient.DoBufferContext(context.Background(), http.DefaultClient, "GET", apiServer.URL, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer token1", buffer.String())
+	RecycleBuffer(buffer)
+
+	bad := NewClientCredentialsTokenSource(&TokenSourceConfig{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "client",
+		ClientSecret: "wrong",
+	})
+	_, err = bad.Token(context.Background())
+	var tokenErr *TokenError
+	assert.True(t, serr.As(err, &tokenErr))
+	assert.Equal(t, "invalid_client", serr.GetCode(err))
+	assert.False(t, serr.IsRetryable(err))
+}
+
+func TestRefreshTokenSource(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set(HEADER_CTYPE, CTYPE_JSON)
+		if r.Form.Get("grant_type") != GRANT_REFRESH_TOKEN || r.Form.Get("refresh_token") != "r1" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"a1","expires_in":1,"refresh_token":"r2"}`))
+	}))
+	defer tokenServer.Close()
+
+	ts := NewRefreshTokenSource(&TokenSourceConfig{TokenURL: tokenServer.URL, ClientID: "client"}, "r1")
+	token, err := ts.Token(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "a1", token.AccessToken)
+	assert.Equal(t, "r2", token.RefreshToken)
+
+	// 令牌在 ExpiryDelta 内过期，使用新的 refresh_token 刷新
+	_, err = ts.Token(context.Background())
+	assert.Equal(t, "invalid_grant", serr.GetCode(err))
+}
@@ -0,0 +1,167 @@
+package shttp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	log "github.com/syncfuture/go/slog"
+	"github.com/syncfuture/go/sproto"
+	"github.com/syncfuture/go/ssecurity"
+)
+
+const (
+	MSGCODE_UNAUTHORIZED = "E_UNAUTHORIZED"
+	MSGCODE_FORBIDDEN    = "E_FORBIDDEN"
+)
+
+type identityKey struct{}
+
+// Identity 当前用户的身份，Roles 为角色位
+type Identity struct {
+	UserID string
+	Roles  int64
+	Level  int32
+}
+
+// WithIdentity 把身份放入 context，供 ContextIdentity 读取，一般由认证中间件调用
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// GetIdentity 从 context 中读取身份，未认证时返回 nil
+func GetIdentity(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityKey{}).(*Identity)
+	return identity
+}
+
+// Route 请求对应的路由，Key 不为空时按路由 key 检查，否则按 area/controller/action 检查
+type Route struct {
+	Key        string
+	Area       string
+	Controller string
+	Action     string
+}
+
+func (x *Route) String() string {
+	if x.Key != "" {
+		return x.Key
+	}
+	return x.Area + "_" + x.Controller + "_" + x.Action
+}
+
+// RouteMapper 根据请求得到路由，返回 nil 表示没有对应的路由
+type RouteMapper func(r *http.Request) *Route
+
+// IdentityExtractor 从请求中获取身份，未认证时返回 nil，返回错误时按未认证处理
+type IdentityExtractor func(r *http.Request) (*Identity, error)
+
+// PathRouteMapper 默认的 RouteMapper，把 /area/controller/action 映射为路由，缺少的部分为空，
+// 由 IPermissionAuditor 按 area_controller_、area__ 依次回退
+func PathRouteMapper(r *http.Request) *Route {
+	parts := strings.SplitN(strings.Trim(r.URL.Path, "/"), "/", 4)
+	if len(parts) == 0 || parts[0] == "" {
+		return nil
+	}
+
+	route := &Route{Area: parts[0]}
+	if len(parts) > 1 {
+		route.Controller = parts[1]
+	}
+	if len(parts) > 2 {
+		route.Action = parts[2]
+	}
+	return route
+}
+
+// ContextIdentity 默认的 IdentityExtractor，从 context 读取 WithIdentity 设置的身份
+func ContextIdentity(r *http.Request) (*Identity, error) {
+	return GetIdentity(r.Context()), nil
+}
+
+type AuthorizeConfig struct {
+	Auditor ssecurity.IPermissionAuditor
+	// RouteMapper 默认 PathRouteMapper
+	RouteMapper RouteMapper
+	// IdentityExtractor 默认 ContextIdentity
+	IdentityExtractor IdentityExtractor
+	// AllowUnmapped 没有对应路由的请求是否放行，默认拒绝
+	AllowUnmapped bool
+}
+
+// Authorize 路由权限中间件，使用 IPermissionAuditor 检查用户的角色和等级，
+// 未认证返回 401，权限不足返回 403，响应体为 sproto.MsgCodeResult，拒绝的原因记录在日志中
+func Authorize(config *AuthorizeConfig) Middleware {
+	if config.Auditor == nil {
+		log.Fatal("auditor cannot be nil")
+	}
+	routeMapper := config.RouteMapper
+	if routeMapper == nil {
+		routeMapper = PathRouteMapper
+	}
+	identityExtractor := config.IdentityExtractor
+	if identityExtractor == nil {
+		identityExtractor = ContextIdentity
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity, err := identityExtractor(r)
+			if err != nil {
+				deny(w, r, http.StatusUnauthorized, nil, nil, "invalid identity: "+err.Error())
+				return
+			}
+
+			route := routeMapper(r)
+			if route == nil {
+				if config.AllowUnmapped {
+					next.ServeHTTP(w, r)
+					return
+				}
+				deny(w, r, http.StatusForbidden, identity, nil, "no route mapped")
+				return
+			}
+
+			var roles int64
+			var level int32
+			if identity != nil {
+				roles, level = identity.Roles, identity.Level
+			}
+
+			var allowed bool
+			if route.Key != "" {
+				allowed = config.Auditor.CheckRouteKeyWithLevel(route.Key, roles, level)
+			} else {
+				allowed = config.Auditor.CheckRouteWithLevel(route.Area, route.Controller, route.Action, roles, level)
+			}
+			switch {
+			case allowed:
+				next.ServeHTTP(w, r)
+			case identity == nil || roles == 0:
+				deny(w, r, http.StatusUnauthorized, identity, route, "not authenticated")
+			default:
+				deny(w, r, http.StatusForbidden, identity, route, "roles or level not permitted")
+			}
+		})
+	}
+}
+
+func deny(w http.ResponseWriter, r *http.Request, statusCode int, identity *Identity, route *Route, reason string) {
+	var user string
+	var roles int64
+	var level int32
+	if identity != nil {
+		user, roles, level = identity.UserID, identity.Roles, identity.Level
+	}
+	var routeKey string
+	if route != nil {
+		routeKey = route.String()
+	}
+	log.Warnf("%s %s denied [%d]: %s, route: %s, user: %s, roles: %d, level: %d", r.Method, r.URL.Path, statusCode, reason, routeKey, user, roles, level)
+
+	msgCode := MSGCODE_FORBIDDEN
+	if statusCode == http.StatusUnauthorized {
+		msgCode = MSGCODE_UNAUTHORIZED
+	}
+	WriteResult(w, r, statusCode, &sproto.MsgCodeResult{MsgCode: msgCode})
+}
@@ -0,0 +1,68 @@
+package shttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testAuditor area_controller_ 需要角色 2，等级 1
+type testAuditor struct{}
+
+func (x *testAuditor) CheckPermission(permissionID string, userRoles int64) bool { return false }
+func (x *testAuditor) CheckPermissionWithLevel(permissionID string, userRoles int64, userLevel int32) bool {
+	return false
+}
+func (x *testAuditor) CheckRoute(area, controller, action string, userRoles int64) bool {
+	return x.CheckRouteWithLevel(area, controller, action, userRoles, 0)
+}
+func (x *testAuditor) CheckRouteWithLevel(area, controller, action string, userRoles int64, userLevel int32) bool {
+	return x.CheckRouteKeyWithLevel(area+"_"+controller+"_", userRoles, userLevel)
+}
+func (x *testAuditor) CheckRouteKeyWithLevel(routeKey string, userRoles int64, userLevel int32) bool {
+	return routeKey == "api_user_" && userRoles&2 > 0 && userLevel >= 1
+}
+
+func TestAuthorize(t *testing.T) {
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), Authorize(&AuthorizeConfig{Auditor: new(testAuditor)}))
+
+	tests := []struct {
+		path       string
+		identity   *Identity
+		statusCode int
+		body       string
+	}{
+		{"/api/user/get", &Identity{UserID: "1", Roles: 2, Level: 1}, http.StatusNoContent, ""},
+		{"/api/user/get", nil, http.StatusUnauthorized, `{"MsgCode":"E_UNAUTHORIZED"}`},
+		{"/api/user/get", &Identity{UserID: "1", Roles: 4, Level: 1}, http.StatusForbidden, `{"MsgCode":"E_FORBIDDEN"}`},
+		{"/api/user/get", &Identity{UserID: "1", Roles: 2}, http.StatusForbidden, `{"MsgCode":"E_FORBIDDEN"}`},
+		{"/", &Identity{UserID: "1", Roles: 2, Level: 1}, http.StatusForbidden, `{"MsgCode":"E_FORBIDDEN"}`},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if test.identity != nil {
+			r = r.WithContext(WithIdentity(r.Context(), test.identity))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, test.statusCode, w.Code, test.path)
+		assert.Equal(t, test.body, w.Body.String())
+	}
+
+	// 自定义路由映射
+	handler = Authorize(&AuthorizeConfig{
+		Auditor:     new(testAuditor),
+		RouteMapper: func(r *http.Request) *Route { return &Route{Key: "api_user_"} },
+		IdentityExtractor: func(r *http.Request) (*Identity, error) {
+			return &Identity{Roles: 2, Level: 1}, nil
+		},
+	})(http.NotFoundHandler())
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/anything", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,286 @@
+package shttp
+
+import (
+	"compress/gzip"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/syncfuture/go/serr"
+)
+
+var (
+	// MaxBindBodySize Bind 读取请求体的最大长度，默认 10MB
+	MaxBindBodySize int64 = 10 << 20
+	// MaxBindMemory 解析 multipart 表单时保存在内存中的最大长度，超过的文件写入临时文件
+	MaxBindMemory int64 = 32 << 20
+)
+
+var (
+	// ErrBodyTooLarge 请求体超过 MaxBindBodySize，WriteError 返回 413
+	ErrBodyTooLarge = serr.New("request body too large")
+
+	_textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// BindError 请求参数无法解析，WriteError 返回 400，请求体过大时返回 413
+type BindError struct {
+	Field string
+	Err   error
+}
+
+func (x *BindError) Error() string {
+	if x.Field == "" {
+		return fmt.Sprintf("invalid request: %v", x.Err)
+	}
+	return fmt.Sprintf("invalid value for '%s': %v", x.Field, x.Err)
+}
+
+func (x *BindError) Unwrap() error {
+	return x.Err
+}
+
+func (x *BindError) HTTPStatus() int {
+	if serr.Is(x.Err, ErrBodyTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// Bind 根据请求的 Content-Type 解析请求体：JSON、protobuf（v 需要实现 proto.Message）、表单和 multipart 表单，
+// Content-Encoding 为 gzip 的请求体会先解压，没有请求体的请求（如 GET）解析查询字符串
+func Bind(r *http.Request, v interface{}) error {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return BindQuery(r, v)
+	}
+	if err := decompressRequest(r); err != nil {
+		return err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get(HEADER_CTYPE))
+	switch mediaType {
+	case CTYPE_FORM, CTYPE_MFORM:
+		return BindForm(r, v)
+	case CTYPE_PROTOBUF:
+		msg, ok := v.(proto.Message)
+		if !ok {
+			return serr.Errorf("%T is not a proto.Message", v)
+		}
+		return BindProto(r, msg)
+	default:
+		return BindJSON(r, v)
+	}
+}
+
+// BindJSON 把 JSON 请求体解析到 v
+func BindJSON(r *http.Request, v interface{}) error {
+	limitBody(r)
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		return bindReadError(err)
+	}
+	return nil
+}
+
+// BindProto 把 protobuf 请求体解析到 msg，使用池中的 buffer 读取
+func BindProto(r *http.Request, msg proto.Message) error {
+	buffer := _bufferPool.GetBuffer()
+	defer _bufferPool.PutBuffer(buffer)
+
+	limitBody(r)
+	_, err := buffer.ReadFrom(r.Body)
+	if err != nil {
+		return bindReadError(err)
+	}
+	err = proto.Unmarshal(buffer.Bytes(), msg)
+	if err != nil {
+		return serr.WithStack(&BindError{Err: err})
+	}
+	return nil
+}
+
+// BindQuery 把查询字符串解析到 v
+func BindQuery(r *http.Request, v interface{}) error {
+	return DecodeForm(r.URL.Query(), v)
+}
+
+// BindForm 解析表单（包括查询字符串）到 v，multipart 表单的文件通过 r.MultipartForm 获取
+func BindForm(r *http.Request, v interface{}) error {
+	limitBody(r)
+
+	var err error
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get(HEADER_CTYPE))
+	if mediaType == CTYPE_MFORM {
+		err = r.ParseMultipartForm(MaxBindMemory)
+	} else {
+		err = r.ParseForm()
+	}
+	if err != nil {
+		return bindReadError(err)
+	}
+	return DecodeForm(r.Form, v)
+}
+
+// DecodeForm 是 EncodeForm 的逆操作，按 form、url 标签的名称把表单值写入结构体字段，
+// 名称可以包含方括号，例如 u.DataTableModel 的 order[0][column]，嵌入的结构体按外层字段处理。
+// 字段可以是基本类型、指针、切片和 encoding.TextUnmarshaler
+func DecodeForm(values url.Values, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return serr.Errorf("cannot decode form into non-pointer %T", v)
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return serr.Errorf("cannot decode form into %T", v)
+	}
+
+	return decodeFormStruct(values, rv)
+}
+
+func decodeFormStruct(values url.Values, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if isEmbeddedStruct(field) {
+			fv := rv.Field(i)
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					if !fv.CanSet() {
+						continue
+					}
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+			err := decodeFormStruct(values, fv)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" { // 未导出
+			continue
+		}
+		name, _ := formFieldName(field)
+		if name == "-" {
+			continue
+		}
+		formValues, ok := values[name]
+		if !ok || len(formValues) == 0 {
+			continue
+		}
+
+		err := setFormField(rv.Field(i), formValues)
+		if err != nil {
+			return serr.WithStack(&BindError{Field: name, Err: err})
+		}
+	}
+	return nil
+}
+
+func setFormField(fv reflect.Value, formValues []string) error {
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 && !reflect.PtrTo(fv.Type()).Implements(_textUnmarshalerType) {
+		slice := reflect.MakeSlice(fv.Type(), len(formValues), len(formValues))
+		for i, s := range formValues {
+			err := setFormValue(slice.Index(i), s)
+			if err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+	return setFormValue(fv, formValues[0])
+}
+
+func setFormValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setFormValue(v.Elem(), s)
+	}
+
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(s))
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		if s == "on" { // HTML checkbox
+			s = "true"
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(s))
+			return nil
+		}
+		fallthrough
+	default:
+		return serr.Errorf("unsupported form value type %s", v.Type())
+	}
+	return nil
+}
+
+// limitBody 限制请求体的长度，超过 MaxBindBodySize 时读取失败。Bind 拿不到 http.ResponseWriter，
+// 所以不会像 MaxBytesReader 通常那样让服务器关闭连接，需要时由调用方自己设置 Connection: close
+func limitBody(r *http.Request) {
+	r.Body = http.MaxBytesReader(nil, r.Body, MaxBindBodySize)
+}
+
+// bindReadError 把读取请求体的错误包装为 BindError，Go 1.16 的 MaxBytesReader 没有错误类型，只能比较消息
+func bindReadError(err error) error {
+	if strings.Contains(err.Error(), "http: request body too large") {
+		err = ErrBodyTooLarge
+	}
+	return serr.WithStack(&BindError{Err: err})
+}
+
+// decompressRequest 解压 Content-Encoding: gzip 的请求体，例如 APIClient.GzipMinSize 压缩的请求
+func decompressRequest(r *http.Request) error {
+	if !strings.EqualFold(r.Header.Get(HEADER_CONTENT_ENCODING), "gzip") {
+		return nil
+	}
+	gz, err := gzip.NewReader(r.Body)
+	if err != nil {
+		return serr.WithStack(&BindError{Err: err})
+	}
+	r.Body = &gzipBody{Reader: gz, body: r.Body}
+	r.Header.Del(HEADER_CONTENT_ENCODING)
+	r.ContentLength = -1
+	return nil
+}
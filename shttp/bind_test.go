@@ -0,0 +1,76 @@
+package shttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/syncfuture/go/serr"
+	"github.com/syncfuture/go/sproto"
+	"github.com/syncfuture/go/u"
+)
+
+type bindModel struct {
+	u.DataTableModel
+	Name    string    `form:"name"`
+	Tags    []string  `form:"tag"`
+	Enabled *bool     `form:"enabled"`
+	Since   time.Time `form:"since"`
+	Skip    string    `form:"-"`
+}
+
+func TestDecodeForm_DataTableModel(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/?draw=2&start=20&length=10&order[0][column]=3&order[0][dir]=desc&search[value]=abc", nil)
+	m := new(u.DataTableModel)
+	assert.NoError(t, BindQuery(r, m))
+	assert.Equal(t, u.DataTableModel{Draw: 2, Start: 20, Length: 10, OrderBy: 3, Sort: "desc", Keyword: "abc"}, *m)
+	assert.Equal(t, int32(3), m.GetPageIndex())
+
+	// 与 EncodeForm 对称
+	values, err := EncodeForm(m)
+	assert.NoError(t, err)
+	m2 := new(u.DataTableModel)
+	assert.NoError(t, DecodeForm(values, m2))
+	assert.Equal(t, m, m2)
+}
+
+func TestBind(t *testing.T) {
+	body := "start=10&length=5&name=a&tag=x&tag=y&enabled=on&since=2021-01-02T03:04:05Z&Skip=1"
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set(HEADER_CTYPE, CTYPE_FORM)
+	m := new(bindModel)
+	assert.NoError(t, Bind(r, m))
+	assert.Equal(t, 10, m.Start)
+	assert.Equal(t, "a", m.Name)
+	assert.Equal(t, []string{"x", "y"}, m.Tags)
+	assert.True(t, *m.Enabled)
+	assert.Equal(t, 2021, m.Since.Year())
+	assert.Empty(t, m.Skip)
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"MsgCode":"E1"}`))
+	r.Header.Set(HEADER_CTYPE, CTYPE_JSON)
+	result := new(sproto.MsgCodeResult)
+	assert.NoError(t, Bind(r, result))
+	assert.Equal(t, "E1", result.MsgCode)
+
+	r = httptest.NewRequest(http.MethodGet, "/?start=abc", nil)
+	err := Bind(r, new(u.DataTableModel))
+	var bindErr *BindError
+	assert.True(t, serr.As(err, &bindErr))
+	assert.Equal(t, "start", bindErr.Field)
+	assert.Equal(t, http.StatusBadRequest, ErrorStatus(err))
+
+	max := MaxBindBodySize
+	MaxBindBodySize = 8
+	defer func() { MaxBindBodySize = max }()
+	for _, ctype := range []string{CTYPE_JSON, CTYPE_PROTOBUF, CTYPE_FORM} {
+		r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"MsgCode":"E1"}`))
+		r.Header.Set(HEADER_CTYPE, ctype)
+		err = Bind(r, new(sproto.MsgCodeResult))
+		assert.True(t, serr.As(err, &bindErr), ctype)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, ErrorStatus(err), ctype)
+	}
+}
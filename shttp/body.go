@@ -0,0 +1,205 @@
+package shttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/syncfuture/go/serr"
+)
+
+// defaultContentType 根据 bodyObj 的类型返回默认的 Content-Type：
+// []byte、string 和其他对象为 JSON（与之前的行为一致）；url.Values 和 Form(v) 为表单；Proto(msg) 为 protobuf；
+// *MultipartBody 使用自己的 Content-Type，其他 io.Reader 为 application/octet-stream
+func defaultContentType(bodyObj interface{}) string {
+	switch v := bodyObj.(type) {
+	case *MultipartBody:
+		return v.ContentType()
+	case io.Reader:
+		return CTYPE_OCTET
+	case url.Values, *formBody:
+		return CTYPE_FORM
+	case *protoBody:
+		return CTYPE_PROTOBUF
+	}
+	return CTYPE_JSON
+}
+
+// encodeBody 按最终的 Content-Type（调用方可以在 configRequest 中覆盖默认值）编码请求体：
+// protobuf 消息（包括 Proto(msg)）在 Content-Type 为 application/x-protobuf 时按 protobuf 编码，否则按 JSON 编码；
+// *MultipartBody 和其他 io.Reader 不缓存，以流的方式发送，不能重试
+func encodeBody(bodyObj interface{}, contentType string) (body *pooledBody, reader io.Reader, err error) {
+	switch v := bodyObj.(type) {
+	case *MultipartBody:
+		return nil, v, nil
+	case io.Reader:
+		return nil, v, nil
+	case *protoBody:
+		bodyObj = v.msg
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	body = newPooledBody()
+	switch v := bodyObj.(type) {
+	case []byte:
+		body.buffer.Write(v)
+	case string:
+		body.buffer.WriteString(v)
+	case url.Values:
+		body.buffer.WriteString(v.Encode())
+	case *formBody:
+		var form url.Values
+		form, err = EncodeForm(v.value)
+		if err == nil {
+			body.buffer.WriteString(form.Encode())
+		}
+	default:
+		if msg, ok := v.(proto.Message); ok && mediaType == CTYPE_PROTOBUF {
+			pb := proto.NewBuffer(body.buffer.Bytes()[:0])
+			err = pb.Marshal(msg)
+			if err == nil {
+				body.buffer.Write(pb.Bytes())
+			}
+			break
+		}
+		err = json.NewEncoder(body.buffer).Encode(v)
+		if err == nil {
+			// 去掉 Encoder 添加的换行，与 json.Marshal 的结果一致
+			body.buffer.Truncate(body.buffer.Len() - 1)
+		}
+	}
+	if err != nil {
+		body.release()
+		return nil, nil, serr.WithStack(err)
+	}
+
+	return body, nil, nil
+}
+
+// compress 使用 gzip 压缩请求体，返回新的 pooledBody，原来的会被释放
+func (x *pooledBody) compress() (*pooledBody, error) {
+	defer x.release()
+
+	r := newPooledBody()
+	gw := gzip.NewWriter(r.buffer)
+	_, err := gw.Write(x.buffer.Bytes())
+	if err == nil {
+		err = gw.Close()
+	}
+	if err != nil {
+		r.release()
+		return nil, serr.WithStack(err)
+	}
+	return r, nil
+}
+
+// pooledBody 请求体使用池中的 buffer，Transport 可能在 client.Do 返回后才关闭请求体（例如 context 被取消时），
+// 因此用引用计数管理：每个 reader 和 DoContext 本身各持有一个引用，全部释放后才归还 buffer
+type pooledBody struct {
+	buffer *bytes.Buffer
+	refs   int32
+}
+
+func newPooledBody() *pooledBody {
+	return &pooledBody{
+		buffer: _bufferPool.GetBuffer(),
+		refs:   1,
+	}
+}
+
+// attach 把请求体设置到 request 上，GetBody 使重定向和重试可以重新读取请求体
+func (x *pooledBody) attach(request *http.Request) {
+	request.ContentLength = int64(x.buffer.Len())
+	if request.ContentLength == 0 {
+		request.Body = http.NoBody
+		request.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+		return
+	}
+
+	request.Body = x.reader()
+	request.GetBody = func() (io.ReadCloser, error) {
+		return x.reader(), nil
+	}
+}
+
+func (x *pooledBody) reader() io.ReadCloser {
+	atomic.AddInt32(&x.refs, 1)
+	return &pooledBodyReader{
+		Reader: bytes.NewReader(x.buffer.Bytes()),
+		body:   x,
+	}
+}
+
+func (x *pooledBody) release() {
+	if x == nil {
+		return
+	}
+	if atomic.AddInt32(&x.refs, -1) == 0 {
+		_bufferPool.PutBuffer(x.buffer)
+		x.buffer = nil
+	}
+}
+
+type pooledBodyReader struct {
+	*bytes.Reader
+	body *pooledBody
+	once sync.Once
+}
+
+func (x *pooledBodyReader) Close() error {
+	x.once.Do(x.body.release)
+	return nil
+}
+
+// cancelBody 响应体关闭时释放超时 context
+type cancelBody struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (x *cancelBody) Close() error {
+	err := x.ReadCloser.Close()
+	x.cancel()
+	return err
+}
+
+// gzipBody 解压 Content-Encoding: gzip 的响应体
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (x *gzipBody) Close() error {
+	x.Reader.Close()
+	return x.body.Close()
+}
+
+// decompressResponse Transport 没有自动解压（例如调用方自己设置了 Accept-Encoding）的 gzip 响应在这里解压
+func decompressResponse(resp *http.Response) error {
+	if resp.Uncompressed || !strings.EqualFold(resp.Header.Get(HEADER_CONTENT_ENCODING), "gzip") || resp.Body == nil || resp.Body == http.NoBody {
+		return nil
+	}
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return nil
+	}
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return serr.WithStack(err)
+	}
+	resp.Body = &gzipBody{Reader: gz, body: resp.Body}
+	resp.Header.Del(HEADER_CONTENT_ENCODING)
+	resp.Header.Del(HEADER_CONTENT_LENGTH)
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
@@ -0,0 +1,48 @@
+package shttp
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type timeoutKey struct{}
+
+// WithTimeout 为单次调用指定超时时间，覆盖 APIClient.Timeout，小于 0 表示不限
+func WithTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutKey{}, timeout)
+}
+
+func getTimeout(ctx context.Context, defaultTimeout time.Duration) time.Duration {
+	if v, ok := ctx.Value(timeoutKey{}).(time.Duration); ok {
+		return v
+	}
+	return defaultTimeout
+}
+
+type requestIDKey struct{}
+
+// WithRequestID 把请求 ID 放入 context，APIClient 发送请求时会设置 X-Request-ID 头
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// GetRequestID 获取 RequestID 中间件或 WithRequestID 放入 context 的请求 ID
+func GetRequestID(ctx context.Context) string {
+	r, _ := ctx.Value(requestIDKey{}).(string)
+	return r
+}
+
+type cacheURLKey struct{}
+
+// withCacheURL 指定 ResponseCache 使用的 URL，例如负载均衡替换端点之前的 URI key URL，为空时使用请求的 URL
+func withCacheURL(ctx context.Context, url string) context.Context {
+	return context.WithValue(ctx, cacheURLKey{}, url)
+}
+
+func getCacheURL(request *http.Request) string {
+	if r, _ := request.Context().Value(cacheURLKey{}).(string); r != "" {
+		return r
+	}
+	return request.URL.String()
+}
@@ -0,0 +1,145 @@
+package shttp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/syncfuture/go/sconfig"
+	log "github.com/syncfuture/go/slog"
+)
+
+const (
+	HEADER_ORIGIN = "Origin"
+	HEADER_ACAO   = "Access-Control-Allow-Origin"
+	HEADER_ACAC   = "Access-Control-Allow-Credentials"
+	HEADER_ACAM   = "Access-Control-Allow-Methods"
+	HEADER_ACAH   = "Access-Control-Allow-Headers"
+	HEADER_ACEH   = "Access-Control-Expose-Headers"
+	HEADER_ACMA   = "Access-Control-Max-Age"
+	HEADER_ACRM   = "Access-Control-Request-Method"
+	HEADER_ACRH   = "Access-Control-Request-Headers"
+)
+
+// CORSPolicy 跨域策略，可以用 LoadCORSPolicy 从配置中读取，例如：
+//
+//	"CORS": { "AllowedOrigins": ["https://*.example.com"], "AllowCredentials": true, "MaxAge": 600 }
+type CORSPolicy struct {
+	// AllowedOrigins 允许的来源，"*" 表示全部，"https://*.example.com" 匹配子域名
+	AllowedOrigins []string
+	// AllowedMethods 默认 GET、HEAD、POST、PUT、PATCH、DELETE
+	AllowedMethods []string
+	// AllowedHeaders 预检请求允许的请求头，为空时允许预检请求中的全部请求头
+	AllowedHeaders []string
+	ExposedHeaders []string
+	// AllowCredentials 不能和 "*" 一起使用，需要携带凭据时请列出具体的来源
+	AllowCredentials bool
+	// MaxAge 预检结果的缓存秒数，0 表示不设置
+	MaxAge int
+}
+
+// LoadCORSPolicy 从配置的 key 节点读取跨域策略
+func LoadCORSPolicy(config sconfig.IConfigProvider, key string) (*CORSPolicy, error) {
+	r := new(CORSPolicy)
+	err := config.GetStruct(key, r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// CORS 跨域中间件，预检请求直接返回 204，来源不允许时不设置跨域响应头，由浏览器拒绝
+func CORS(policy *CORSPolicy) Middleware {
+	p := *policy
+	if p.AllowCredentials {
+		for _, allowed := range p.AllowedOrigins {
+			if allowed == "*" {
+				log.Fatal(`CORS policy cannot allow credentials with AllowedOrigins "*"`)
+			}
+		}
+	}
+	if len(p.AllowedMethods) == 0 {
+		p.AllowedMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	}
+	allowedMethods := strings.Join(p.AllowedMethods, ", ")
+	allowedHeaders := strings.Join(p.AllowedHeaders, ", ")
+	exposedHeaders := strings.Join(p.ExposedHeaders, ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get(HEADER_ORIGIN)
+			preflight := r.Method == http.MethodOptions && r.Header.Get(HEADER_ACRM) != ""
+			header := w.Header()
+			header.Add(HEADER_VARY, HEADER_ORIGIN)
+
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowOrigin := p.allowOrigin(origin)
+			if preflight {
+				header.Add(HEADER_VARY, HEADER_ACRM)
+				header.Add(HEADER_VARY, HEADER_ACRH)
+				if allowOrigin != "" && p.allowMethod(r.Header.Get(HEADER_ACRM)) {
+					p.setOrigin(header, allowOrigin)
+					header.Set(HEADER_ACAM, allowedMethods)
+					if allowedHeaders != "" {
+						header.Set(HEADER_ACAH, allowedHeaders)
+					} else if requested := r.Header.Get(HEADER_ACRH); requested != "" {
+						header.Set(HEADER_ACAH, requested)
+					}
+					if p.MaxAge > 0 {
+						header.Set(HEADER_ACMA, strconv.Itoa(p.MaxAge))
+					}
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if allowOrigin != "" {
+				p.setOrigin(header, allowOrigin)
+				if exposedHeaders != "" {
+					header.Set(HEADER_ACEH, exposedHeaders)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// allowOrigin 返回 Access-Control-Allow-Origin 的值，不允许时返回空字符串
+func (x *CORSPolicy) allowOrigin(origin string) string {
+	for _, allowed := range x.AllowedOrigins {
+		switch {
+		case allowed == "*":
+			return "*"
+		case strings.EqualFold(allowed, origin):
+			return origin
+		case strings.Contains(allowed, "*"):
+			i := strings.Index(allowed, "*")
+			prefix, suffix := strings.ToLower(allowed[:i]), strings.ToLower(allowed[i+1:])
+			o := strings.ToLower(origin)
+			if len(o) > len(prefix)+len(suffix) && strings.HasPrefix(o, prefix) && strings.HasSuffix(o, suffix) {
+				return origin
+			}
+		}
+	}
+	return ""
+}
+
+func (x *CORSPolicy) allowMethod(method string) bool {
+	for _, m := range x.AllowedMethods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (x *CORSPolicy) setOrigin(header http.Header, allowOrigin string) {
+	header.Set(HEADER_ACAO, allowOrigin)
+	if x.AllowCredentials && allowOrigin != "*" {
+		header.Set(HEADER_ACAC, "true")
+	}
+}
@@ -0,0 +1,323 @@
+package shttp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/syncfuture/go/serr"
+	log "github.com/syncfuture/go/slog"
+)
+
+const (
+	HEADER_RANGE         = "Range"
+	HEADER_IF_RANGE      = "If-Range"
+	HEADER_CONTENT_RANGE = "Content-Range"
+	HEADER_ACCEPT_RANGES = "Accept-Ranges"
+	HEADER_ETAG          = "ETag"
+	HEADER_LAST_MODIFIED = "Last-Modified"
+)
+
+type DownloadOptions struct {
+	// OnProgress 每次写入后调用，total 未知时为 -1
+	OnProgress func(written, total int64)
+	// Checksum 期望的校验值（十六进制），为空时不校验
+	Checksum string
+	// Hash 校验使用的算法，默认 sha256
+	Hash func() hash.Hash
+	// MaxResumes 连接中断后最多续传的次数，默认 3，服务器不支持 Range 时不续传
+	MaxResumes int
+}
+
+// ChecksumError 下载内容的校验值与期望的不一致
+type ChecksumError struct {
+	Expected string
+	Actual   string
+}
+
+func (x *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch, expected %s, got %s", x.Expected, x.Actual)
+}
+
+func (x *ChecksumError) Retryable() bool {
+	return false
+}
+
+// Download 把响应体流式写入 w，不会整体缓存，连接中断时使用 Range 请求续传。
+// APIClient.Timeout 包含下载时间，大文件可以用 WithTimeout(ctx, -1) 取消超时
+func (x *APIClient) Download(ctx context.Context, client *http.Client, url string, configRequest func(*http.Request), w io.Writer, options *DownloadOptions) (written int64, err error) {
+	opts := options.withDefaults()
+	var h hash.Hash
+	if opts.Checksum != "" {
+		h = opts.Hash()
+	}
+	return x.download(ctx, client, url, configRequest, w, 0, "", nil, h, opts)
+}
+
+// DownloadFile 下载到文件，先写入 file.part，完成并校验通过后重命名为 file。
+// 响应的 ETag 或 Last-Modified 保存在 file.part.meta 中，file.part 已经存在时（上次下载中断）
+// 用它作为 If-Range 从末尾续传，没有 file.part.meta 时无法确认内容没有变化，从头下载
+func (x *APIClient) DownloadFile(ctx context.Context, client *http.Client, url string, configRequest func(*http.Request), file string, options *DownloadOptions) (written int64, err error) {
+	opts := options.withDefaults()
+	partFile := file + ".part"
+	metaFile := partFile + ".meta"
+
+	f, err := os.OpenFile(partFile, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return 0, serr.WithStack(err)
+	}
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	var h hash.Hash
+	if opts.Checksum != "" {
+		h = opts.Hash()
+	}
+
+	var validator string
+	if meta, err := os.ReadFile(metaFile); err == nil {
+		validator = strings.TrimSpace(string(meta))
+	}
+
+	// 续传时已有内容也要参与校验
+	var offset int64
+	if validator == "" {
+		err = restart(f)
+	} else if h != nil {
+		offset, err = io.Copy(h, f)
+	} else {
+		offset, err = f.Seek(0, io.SeekEnd)
+	}
+	if err != nil {
+		return 0, serr.WithStack(err)
+	}
+
+	saveValidator := func(v string) error {
+		if v == "" {
+			err := os.Remove(metaFile)
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return serr.WithStack(err)
+		}
+		return serr.WithStack(os.WriteFile(metaFile, []byte(v), 0644))
+	}
+
+	written, err = x.download(ctx, client, url, configRequest, f, offset, validator, saveValidator, h, opts)
+	if err != nil {
+		var checksumErr *ChecksumError
+		if serr.As(err, &checksumErr) {
+			f.Close()
+			f = nil
+			os.Remove(partFile)
+			os.Remove(metaFile)
+		}
+		return written, err
+	}
+
+	err = f.Close()
+	f = nil
+	if err != nil {
+		return written, serr.WithStack(err)
+	}
+	err = os.Rename(partFile, file)
+	if err != nil {
+		return written, serr.WithStack(err)
+	}
+	os.Remove(metaFile)
+	return written, nil
+}
+
+// download 从 offset 开始下载，validator 是 offset 之前内容的 ETag 或 Last-Modified，
+// 没有 validator 时不发送 Range，由服务器返回完整内容从头写入；收到完整内容时调用 saveValidator 保存新的 validator
+func (x *APIClient) download(ctx context.Context, client *http.Client, url string, configRequest func(*http.Request), w io.Writer, offset int64, validator string, saveValidator func(string) error, h hash.Hash, opts DownloadOptions) (int64, error) {
+	pw := &progressWriter{
+		writer:     w,
+		hash:       h,
+		written:    offset,
+		total:      -1,
+		onProgress: opts.OnProgress,
+	}
+
+	acceptRanges := false
+	for resumes := 0; ; resumes++ {
+		from := pw.written
+		resp, err := x.DoContext(ctx, client, http.MethodGet, url, func(r *http.Request) {
+			r.Header.Del(HEADER_CTYPE)
+			// 只有 If-Range 能保证续传的内容和已有的内容属于同一个版本
+			if from > 0 && validator != "" {
+				r.Header.Set(HEADER_RANGE, fmt.Sprintf("bytes=%d-", from))
+				r.Header.Set(HEADER_IF_RANGE, validator)
+			}
+			if configRequest != nil {
+				configRequest(r)
+			}
+		}, nil)
+		if err != nil {
+			return pw.written, err
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusPartialContent && from > 0:
+			start, total := parseContentRange(resp.Header.Get(HEADER_CONTENT_RANGE))
+			if start != from {
+				resp.Body.Close()
+				return pw.written, serr.Errorf("unexpected Content-Range '%s' for offset %d", resp.Header.Get(HEADER_CONTENT_RANGE), from)
+			}
+			pw.total = total
+		case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && from > 0:
+			// 已经下载完整
+			_, total := parseContentRange(resp.Header.Get(HEADER_CONTENT_RANGE))
+			drainAndClose(resp.Body)
+			if total >= 0 && total != from {
+				return pw.written, serr.Errorf("range not satisfiable, local size %d, remote size %d", from, total)
+			}
+			return pw.written, opts.verify(h)
+		case IsSuccessStatus(resp.StatusCode):
+			if from > 0 {
+				// 服务器忽略了 Range 或者内容已经变化，从头开始
+				err = restart(w)
+				if err != nil {
+					resp.Body.Close()
+					return pw.written, err
+				}
+				pw.written = 0
+				if h != nil {
+					h.Reset()
+				}
+			}
+			pw.total = resp.ContentLength
+		default:
+			buffer := _bufferPool.GetBuffer()
+			buffer.ReadFrom(io.LimitReader(resp.Body, _maxErrorBodySnippet))
+			resp.Body.Close()
+			httpErr := NewHTTPError(resp, buffer.Bytes(), x.ErrorDecoder)
+			_bufferPool.PutBuffer(buffer)
+			return pw.written, serr.WithStack(httpErr)
+		}
+
+		acceptRanges = acceptRanges || resp.Header.Get(HEADER_ACCEPT_RANGES) == "bytes" || resp.StatusCode == http.StatusPartialContent
+		if resp.StatusCode != http.StatusPartialContent {
+			// 新的完整内容，206 时沿用已有内容的 validator
+			v := resp.Header.Get(HEADER_ETAG)
+			if v == "" || strings.HasPrefix(v, "W/") {
+				v = resp.Header.Get(HEADER_LAST_MODIFIED)
+			}
+			if saveValidator != nil {
+				if err = saveValidator(v); err != nil {
+					resp.Body.Close()
+					return pw.written, err
+				}
+			}
+			validator = v
+		}
+
+		_, err = io.Copy(pw, resp.Body)
+		resp.Body.Close()
+		if err == nil {
+			break
+		}
+		if pw.err != nil || ctx.Err() != nil || !acceptRanges || resumes >= opts.MaxResumes {
+			return pw.written, serr.WithStack(err)
+		}
+		log.Warnf("GET %s interrupted at %d bytes, resuming: %v", url, pw.written, err)
+	}
+
+	return pw.written, opts.verify(h)
+}
+
+func (x *DownloadOptions) withDefaults() DownloadOptions {
+	var r DownloadOptions
+	if x != nil {
+		r = *x
+	}
+	if r.Hash == nil {
+		r.Hash = sha256.New
+	}
+	if r.MaxResumes == 0 {
+		r.MaxResumes = 3
+	}
+	return r
+}
+
+func (x *DownloadOptions) verify(h hash.Hash) error {
+	if h == nil {
+		return nil
+	}
+	actual := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(actual, x.Checksum) {
+		return serr.WithStack(&ChecksumError{Expected: x.Checksum, Actual: actual})
+	}
+	return nil
+}
+
+// restart 服务器不支持续传时，可以截断的 writer（例如 *os.File）从头写入
+func restart(w io.Writer) error {
+	t, ok := w.(interface {
+		io.Seeker
+		Truncate(size int64) error
+	})
+	if !ok {
+		return serr.New("server does not support resuming and the writer cannot be truncated")
+	}
+	err := t.Truncate(0)
+	if err == nil {
+		_, err = t.Seek(0, io.SeekStart)
+	}
+	return serr.WithStack(err)
+}
+
+// parseContentRange 解析 "bytes 100-199/1000" 或 "bytes */1000"，未知的部分返回 -1
+func parseContentRange(v string) (start, total int64) {
+	start, total = -1, -1
+	v = strings.TrimPrefix(strings.TrimSpace(v), "bytes ")
+	slash := strings.Index(v, "/")
+	if slash < 0 {
+		return
+	}
+	if t, err := strconv.ParseInt(v[slash+1:], 10, 64); err == nil {
+		total = t
+	}
+	if dash := strings.Index(v[:slash], "-"); dash > 0 {
+		if s, err := strconv.ParseInt(v[:dash], 10, 64); err == nil {
+			start = s
+		}
+	}
+	return
+}
+
+type progressWriter struct {
+	writer     io.Writer
+	hash       hash.Hash
+	written    int64
+	total      int64
+	onProgress func(written, total int64)
+	err        error
+}
+
+func (x *progressWriter) Write(p []byte) (int, error) {
+	n, err := x.writer.Write(p)
+	if n > 0 {
+		if x.hash != nil {
+			x.hash.Write(p[:n])
+		}
+		x.written += int64(n)
+		if x.onProgress != nil {
+			x.onProgress(x.written, x.total)
+		}
+	}
+	if err != nil {
+		x.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,96 @@
+package shttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/syncfuture/go/serr"
+)
+
+func TestAPIClient_Download(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 10000))
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	var requests int32
+	var lastRange atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// 第一次只发送一半内容后断开连接
+			w.Header().Set(HEADER_ACCEPT_RANGES, "bytes")
+			w.Header().Set(HEADER_ETAG, `"v1"`)
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		lastRange.Store(r.Header.Get(HEADER_RANGE))
+		w.Header().Set(HEADER_ETAG, `"v1"`)
+		http.ServeContent(w, r, "data.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	apiClient := new(APIClient)
+	var progress int64
+	buffer := new(bytes.Buffer)
+	written, err := apiClient.Download(context.Background(), http.DefaultClient, server.URL, nil, buffer, &DownloadOptions{
+		Checksum: checksum,
+		OnProgress: func(written, total int64) {
+			progress = written
+			assert.Equal(t, int64(len(content)), total)
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(content)), written)
+	assert.Equal(t, written, progress)
+	assert.Equal(t, content, buffer.Bytes())
+	assert.Equal(t, int32(2), requests)
+
+	_, err = apiClient.Download(context.Background(), http.DefaultClient, server.URL, nil, new(bytes.Buffer), &DownloadOptions{Checksum: "00"})
+	var checksumErr *ChecksumError
+	assert.True(t, serr.As(err, &checksumErr))
+
+	// 从上次中断的 .part 文件续传
+	file := filepath.Join(t.TempDir(), "data.txt")
+	os.WriteFile(file+".part", content[:1000], 0644)
+	os.WriteFile(file+".part.meta", []byte(`"v1"`), 0644)
+	written, err = apiClient.DownloadFile(context.Background(), http.DefaultClient, server.URL, nil, file, &DownloadOptions{Checksum: checksum})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(content)), written)
+	assert.Equal(t, "bytes=1000-", lastRange.Load())
+	data, _ := os.ReadFile(file)
+	assert.Equal(t, content, data)
+	_, err = os.Stat(file + ".part")
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(file + ".part.meta")
+	assert.True(t, os.IsNotExist(err))
+
+	// 没有 .part.meta 或者内容已经变化时从头下载
+	for _, meta := range []string{"", `"v0"`} {
+		os.Remove(file)
+		os.WriteFile(file+".part", []byte("stale"), 0644)
+		if meta != "" {
+			os.WriteFile(file+".part.meta", []byte(meta), 0644)
+		}
+		written, err = apiClient.DownloadFile(context.Background(), http.DefaultClient, server.URL, nil, file, &DownloadOptions{Checksum: checksum})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(content)), written)
+		data, _ = os.ReadFile(file)
+		assert.Equal(t, content, data)
+		if meta == "" {
+			assert.Equal(t, "", lastRange.Load())
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package shttp
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/syncfuture/go/serr"
+)
+
+// formBody 包装一个结构体或 map，按表单编码发送
+type formBody struct {
+	value interface{}
+}
+
+// Form 把结构体或 map[string]string 按 application/x-www-form-urlencoded 发送，结构体字段名取自 form 标签，
+// 其次是 url 标签，例如 u.DataTableModel
+func Form(v interface{}) interface{} {
+	return &formBody{value: v}
+}
+
+// EncodeForm 把结构体、map 或 url.Values 编码为表单，支持 omitempty 和 "-"
+func EncodeForm(v interface{}) (url.Values, error) {
+	switch m := v.(type) {
+	case url.Values:
+		return m, nil
+	case map[string]string:
+		r := make(url.Values, len(m))
+		for k, v := range m {
+			r.Set(k, v)
+		}
+		return r, nil
+	case map[string][]string:
+		return url.Values(m), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return url.Values{}, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, serr.Errorf("cannot encode %T as form", v)
+	}
+
+	r := make(url.Values)
+	return r, encodeFormStruct(r, rv)
+}
+
+func encodeFormStruct(r url.Values, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if isEmbeddedStruct(field) {
+			fv := rv.Field(i)
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			err := encodeFormStruct(r, fv)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" { // 未导出
+			continue
+		}
+		name, omitEmpty := formFieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		fv := rv.Field(i)
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+		for fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				break
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Ptr {
+			continue
+		}
+
+		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+			for j := 0; j < fv.Len(); j++ {
+				s, err := formatFormValue(fv.Index(j))
+				if err != nil {
+					return serr.WithMessagef(err, "field %s", field.Name)
+				}
+				r.Add(name, s)
+			}
+			continue
+		}
+
+		s, err := formatFormValue(fv)
+		if err != nil {
+			return serr.WithMessagef(err, "field %s", field.Name)
+		}
+		r.Set(name, s)
+	}
+	return nil
+}
+
+// isEmbeddedStruct 没有标签的匿名结构体字段（例如嵌入的 u.DataTableModel），其字段按外层字段处理
+func isEmbeddedStruct(field reflect.StructField) bool {
+	if !field.Anonymous {
+		return false
+	}
+	if _, ok := field.Tag.Lookup("form"); ok {
+		return false
+	}
+	if _, ok := field.Tag.Lookup("url"); ok {
+		return false
+	}
+	t := field.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+// formFieldName 依次使用 form、url 标签，最后使用字段名
+func formFieldName(field reflect.StructField) (name string, omitEmpty bool) {
+	tag, ok := field.Tag.Lookup("form")
+	if !ok {
+		tag, ok = field.Tag.Lookup("url")
+	}
+	if !ok {
+		return field.Name, false
+	}
+
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty
+}
+
+func formatFormValue(v reflect.Value) (string, error) {
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), nil
+		}
+	}
+	return "", serr.Errorf("unsupported form value type %s", v.Type())
+}
+
+// MultipartBody 流式发送的 multipart/form-data 请求体，文件内容在发送时才从 io.Reader 读取，不会整体缓存，
+// 因此只能发送一次，也不会被重试
+type MultipartBody struct {
+	parts  []multipartPart
+	reader *io.PipeReader
+	writer *io.PipeWriter
+	mw     *multipart.Writer
+	once   sync.Once
+}
+
+type multipartPart struct {
+	fieldName string
+	fileName  string
+	value     string
+	reader    io.Reader
+}
+
+func NewMultipartBody() *MultipartBody {
+	r := new(MultipartBody)
+	r.reader, r.writer = io.Pipe()
+	r.mw = multipart.NewWriter(r.writer)
+	return r
+}
+
+func (x *MultipartBody) AddField(name, value string) *MultipartBody {
+	x.parts = append(x.parts, multipartPart{fieldName: name, value: value})
+	return x
+}
+
+// AddFile 添加文件，reader 由调用方负责关闭
+func (x *MultipartBody) AddFile(fieldName, fileName string, reader io.Reader) *MultipartBody {
+	x.parts = append(x.parts, multipartPart{fieldName: fieldName, fileName: fileName, reader: reader})
+	return x
+}
+
+// ContentType 包含 boundary 的 Content-Type
+func (x *MultipartBody) ContentType() string {
+	return x.mw.FormDataContentType()
+}
+
+// Read 第一次读取时才开始写入，请求没有发出时不会留下阻塞的 goroutine
+func (x *MultipartBody) Read(p []byte) (int, error) {
+	x.once.Do(func() {
+		go x.write()
+	})
+	return x.reader.Read(p)
+}
+
+func (x *MultipartBody) Close() error {
+	return x.reader.Close()
+}
+
+func (x *MultipartBody) write() {
+	var err error
+	for _, part := range x.parts {
+		if part.reader == nil {
+			err = x.mw.WriteField(part.fieldName, part.value)
+		} else {
+			var w io.Writer
+			w, err = x.mw.CreateFormFile(part.fieldName, part.fileName)
+			if err == nil {
+				_, err = io.Copy(w, part.reader)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = x.mw.Close()
+	}
+	x.writer.CloseWithError(err)
+}
@@ -0,0 +1,69 @@
+package shttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/syncfuture/go/u"
+)
+
+func TestEncodeForm(t *testing.T) {
+	form, err := EncodeForm(&u.DataTableModel{Draw: 1, Start: 20, Length: 10, Sort: "asc", Keyword: "abc"})
+	assert.NoError(t, err)
+	assert.Equal(t, "20", form.Get("start"))
+	assert.Equal(t, "asc", form.Get("order[0][dir]"))
+	assert.Equal(t, "abc", form.Get("search[value]"))
+
+	form, err = EncodeForm(struct {
+		Name   string   `form:"name"`
+		Tags   []string `form:"tag"`
+		Empty  string   `form:"empty,omitempty"`
+		Skip   string   `form:"-"`
+		hidden string
+	}{Name: "a", Tags: []string{"x", "y"}, Skip: "s", hidden: "h"})
+	assert.NoError(t, err)
+	assert.Equal(t, url.Values{"name": {"a"}, "tag": {"x", "y"}}, form)
+}
+
+func TestAPIClient_FormBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctype := r.Header.Get(HEADER_CTYPE)
+		if strings.HasPrefix(ctype, CTYPE_MFORM) {
+			r.ParseMultipartForm(1 << 20)
+			file, header, _ := r.FormFile("file")
+			data, _ := io.ReadAll(file)
+			io.WriteString(w, r.FormValue("name")+"|"+header.Filename+"|"+string(data))
+			return
+		}
+		r.ParseForm()
+		io.WriteString(w, ctype+"|"+r.PostForm.Encode())
+	}))
+	defer server.Close()
+
+	apiClient := new(APIClient)
+	ctx := context.Background()
+
+	buffer, err := apiClient.DoBufferContext(ctx, http.DefaultClient, "POST", server.URL, nil, url.Values{"a": {"1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, CTYPE_FORM+"|a=1", buffer.String())
+	RecycleBuffer(buffer)
+
+	buffer, err = apiClient.DoBufferContext(ctx, http.DefaultClient, "POST", server.URL, nil, Form(&u.DataTableModel{Draw: 2}))
+	assert.NoError(t, err)
+	assert.Contains(t, buffer.String(), "draw=2")
+	RecycleBuffer(buffer)
+
+	body := NewMultipartBody().
+		AddField("name", "report").
+		AddFile("file", "report.csv", strings.NewReader("a,b,c"))
+	buffer, err = apiClient.DoBufferContext(ctx, http.DefaultClient, "POST", server.URL, nil, body)
+	assert.NoError(t, err)
+	assert.Equal(t, "report|report.csv|a,b,c", buffer.String())
+	RecycleBuffer(buffer)
+}
@@ -0,0 +1,156 @@
+package shttp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/syncfuture/go/sid"
+	log "github.com/syncfuture/go/slog"
+)
+
+const (
+	HEADER_REQUEST_ID = "X-Request-ID"
+)
+
+var (
+	// RedactedHeaders 日志中需要隐藏值的请求头
+	RedactedHeaders = []string{HEADER_AUTH, "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+)
+
+// Invoker 发送请求并返回响应
+type Invoker func(*http.Request) (*http.Response, error)
+
+// Interceptor 拦截器，可以修改请求、调用 next 发送请求，并检查或替换响应，每次重试都会经过拦截器
+type Interceptor func(request *http.Request, next Invoker) (*http.Response, error)
+
+type interceptorsKey struct{}
+
+// WithInterceptors 为单次调用追加拦截器，在 APIClient.Interceptors 之后执行
+func WithInterceptors(ctx context.Context, interceptors ...Interceptor) context.Context {
+	existing, _ := ctx.Value(interceptorsKey{}).([]Interceptor)
+	r := make([]Interceptor, 0, len(existing)+len(interceptors))
+	r = append(r, existing...)
+	r = append(r, interceptors...)
+	return context.WithValue(ctx, interceptorsKey{}, r)
+}
+
+func getInterceptors(ctx context.Context, clientInterceptors []Interceptor) []Interceptor {
+	callInterceptors, _ := ctx.Value(interceptorsKey{}).([]Interceptor)
+	if len(callInterceptors) == 0 {
+		return clientInterceptors
+	}
+	r := make([]Interceptor, 0, len(clientInterceptors)+len(callInterceptors))
+	r = append(r, clientInterceptors...)
+	return append(r, callInterceptors...)
+}
+
+// ChainInterceptors 把多个拦截器组合为一个，第一个拦截器在最外层
+func ChainInterceptors(interceptors ...Interceptor) Interceptor {
+	return func(request *http.Request, next Invoker) (*http.Response, error) {
+		return chainInvoker(interceptors, next)(request)
+	}
+}
+
+func chainInvoker(interceptors []Interceptor, invoker Invoker) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(r *http.Request) (*http.Response, error) {
+			return interceptor(r, next)
+		}
+	}
+	return invoker
+}
+
+// AuthInterceptor 使用 getAuth 返回的值设置 Authorization 头
+func AuthInterceptor(getAuth func(ctx context.Context) (string, error)) Interceptor {
+	return func(request *http.Request, next Invoker) (*http.Response, error) {
+		auth, err := getAuth(request.Context())
+		if err != nil {
+			return nil, err
+		}
+		if auth != "" {
+			request.Header.Set(HEADER_AUTH, auth)
+		}
+		return next(request)
+	}
+}
+
+// BearerInterceptor 使用 getToken 返回的 token 设置 Authorization: Bearer 头
+func BearerInterceptor(getToken func(ctx context.Context) (string, error)) Interceptor {
+	return AuthInterceptor(func(ctx context.Context) (string, error) {
+		token, err := getToken(ctx)
+		if err != nil || token == "" {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	})
+}
+
+// RequestIDInterceptor 请求没有 X-Request-ID 头时使用 context 中的请求 ID（见 WithRequestID），没有时使用 generator 生成
+func RequestIDInterceptor(generator sid.IIDGenerator) Interceptor {
+	return func(request *http.Request, next Invoker) (*http.Response, error) {
+		if request.Header.Get(HEADER_REQUEST_ID) == "" {
+			requestID := GetRequestID(request.Context())
+			if requestID == "" {
+				requestID = generator.GenerateString()
+			}
+			request.Header.Set(HEADER_REQUEST_ID, requestID)
+		}
+		return next(request)
+	}
+}
+
+// LoggingInterceptor 通过 slog 记录请求的状态和耗时，4xx/5xx 和错误记录为 Warn，logHeaders 为 true 时同时记录隐藏敏感值后的请求头
+func LoggingInterceptor(logHeaders bool) Interceptor {
+	return func(request *http.Request, next Invoker) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next(request)
+		elapsed := time.Since(start)
+
+		var headers string
+		if logHeaders {
+			headers = " " + formatHeaders(RedactHeaders(request.Header))
+		}
+
+		if err != nil {
+			log.Warnf("%s %s [ERR] %s%s -> %v", request.Method, request.URL.String(), elapsed, headers, err)
+		} else if resp.StatusCode >= http.StatusBadRequest {
+			log.Warnf("%s %s [%d] %s%s", request.Method, request.URL.String(), resp.StatusCode, elapsed, headers)
+		} else {
+			log.Debugf("%s %s [%d] %s%s", request.Method, request.URL.String(), resp.StatusCode, elapsed, headers)
+		}
+		return resp, err
+	}
+}
+
+// RedactHeaders 返回隐藏了 RedactedHeaders 中请求头的值的副本
+func RedactHeaders(header http.Header) http.Header {
+	r := header.Clone()
+	for _, name := range RedactedHeaders {
+		if values, ok := r[http.CanonicalHeaderKey(name)]; ok {
+			for i := range values {
+				values[i] = "***"
+			}
+		}
+	}
+	return r
+}
+
+func formatHeaders(header http.Header) string {
+	var sb strings.Builder
+	sb.WriteString("{")
+	first := true
+	for k, v := range header {
+		if !first {
+			sb.WriteString(", ")
+		}
+		first = false
+		sb.WriteString(k)
+		sb.WriteString(": ")
+		sb.WriteString(strings.Join(v, ","))
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
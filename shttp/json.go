@@ -0,0 +1,78 @@
+package shttp
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/syncfuture/go/serr"
+)
+
+// GetJSON 发送 GET 请求并把 JSON 响应解析到 result，非 2xx 响应返回 *HTTPError
+func (x *APIClient) GetJSON(ctx context.Context, client *http.Client, url string, configRequest func(*http.Request), result interface{}) error {
+	return x.DoJSON(ctx, client, http.MethodGet, url, configRequest, nil, result)
+}
+
+func (x *APIClient) PostJSON(ctx context.Context, client *http.Client, url string, configRequest func(*http.Request), bodyObj, result interface{}) error {
+	return x.DoJSON(ctx, client, http.MethodPost, url, configRequest, bodyObj, result)
+}
+
+func (x *APIClient) PutJSON(ctx context.Context, client *http.Client, url string, configRequest func(*http.Request), bodyObj, result interface{}) error {
+	return x.DoJSON(ctx, client, http.MethodPut, url, configRequest, bodyObj, result)
+}
+
+func (x *APIClient) DeleteJSON(ctx context.Context, client *http.Client, url string, configRequest func(*http.Request), result interface{}) error {
+	return x.DoJSON(ctx, client, http.MethodDelete, url, configRequest, nil, result)
+}
+
+// DoJSON 发送请求并把 JSON 响应解析到 result，result 为 nil 时丢弃响应体，非 2xx 响应返回 *HTTPError
+func (x *APIClient) DoJSON(ctx context.Context, client *http.Client, method, url string, configRequest func(*http.Request), bodyObj, result interface{}) error {
+	return x.doResult(ctx, client, method, url, CTYPE_JSON, configRequest, bodyObj, result)
+}
+
+// doResult 发送请求并根据响应的 Content-Type 解析响应体：protobuf 响应解析到 proto.Message，其他按 JSON 解析
+func (x *APIClient) doResult(ctx context.Context, client *http.Client, method, url, accept string, configRequest func(*http.Request), bodyObj, result interface{}) error {
+	resp, err := x.DoContext(ctx, client, method, url, func(r *http.Request) {
+		r.Header.Set(HEADER_ACCEPT, accept)
+		if configRequest != nil {
+			configRequest(r)
+		}
+	}, bodyObj)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 读取Response Body
+	buffer := _bufferPool.GetBuffer()
+	defer _bufferPool.PutBuffer(buffer)
+	_, err = buffer.ReadFrom(resp.Body)
+	if err != nil {
+		return serr.WithStack(err)
+	}
+
+	if !IsSuccessStatus(resp.StatusCode) {
+		return serr.WithStack(NewHTTPError(resp, buffer.Bytes(), x.ErrorDecoder))
+	}
+
+	if result == nil || buffer.Len() == 0 || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get(HEADER_CTYPE))
+	if mediaType == CTYPE_PROTOBUF {
+		msg, ok := result.(proto.Message)
+		if !ok {
+			return serr.Errorf("%s %s: cannot decode protobuf response into %T", method, resp.Request.URL.String(), result)
+		}
+		err = proto.Unmarshal(buffer.Bytes(), msg)
+	} else {
+		err = json.Unmarshal(buffer.Bytes(), result)
+	}
+	if err != nil {
+		return serr.Wrapf(err, "%s %s: decode response failed", method, resp.Request.URL.String())
+	}
+	return nil
+}
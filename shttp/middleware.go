@@ -0,0 +1,50 @@
+package shttp
+
+import (
+	"net/http"
+
+	"github.com/syncfuture/go/sid"
+)
+
+const (
+	_maxRequestIDLength = 128
+)
+
+// Middleware net/http 中间件
+type Middleware func(http.Handler) http.Handler
+
+// Chain 按顺序组合中间件，第一个中间件在最外层
+func Chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// RequestID 使用请求的 X-Request-ID 头，没有或者不合法时用 generator 生成，
+// 请求 ID 写入响应头并放入 context（见 GetRequestID），APIClient 在同一个 context 下发出的请求会带上它
+func RequestID(generator sid.IIDGenerator) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(HEADER_REQUEST_ID)
+			if !isValidRequestID(requestID) {
+				requestID = generator.GenerateString()
+			}
+			w.Header().Set(HEADER_REQUEST_ID, requestID)
+			next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), requestID)))
+		})
+	}
+}
+
+// isValidRequestID 只接受可打印的 ASCII 字符，避免日志注入
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > _maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] <= ' ' || requestID[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package shttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/syncfuture/go/sconfig"
+)
+
+func TestCORS(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "configs.json")
+	os.WriteFile(file, []byte(`{"CORS":{"AllowedOrigins":["https://*.example.com"],"AllowCredentials":true,"ExposedHeaders":["X-Request-ID"],"MaxAge":600}}`), 0644)
+	policy, err := LoadCORSPolicy(sconfig.NewJsonConfigProvider(file), "CORS")
+	assert.NoError(t, err)
+
+	handler := CORS(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// 预检
+	r := httptest.NewRequest(http.MethodOptions, "/", nil)
+	r.Header.Set(HEADER_ORIGIN, "https://app.example.com")
+	r.Header.Set(HEADER_ACRM, http.MethodPut)
+	r.Header.Set(HEADER_ACRH, "X-Custom")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get(HEADER_ACAO))
+	assert.Equal(t, "true", w.Header().Get(HEADER_ACAC))
+	assert.Equal(t, "X-Custom", w.Header().Get(HEADER_ACAH))
+	assert.Equal(t, "600", w.Header().Get(HEADER_ACMA))
+
+	// 普通请求
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(HEADER_ORIGIN, "https://app.example.com")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get(HEADER_ACAO))
+	assert.Equal(t, "X-Request-ID", w.Header().Get(HEADER_ACEH))
+
+	// 不允许的来源
+	for _, origin := range []string{"https://example.com", "https://evil.com", "http://app.example.com"} {
+		r = httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(HEADER_ORIGIN, origin)
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Empty(t, w.Header().Get(HEADER_ACAO), origin)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	handler := SecurityHeaders(&SecurityHeadersConfig{ContentSecurityPolicy: "default-src 'self'", HSTSIncludeSubdomains: true})(http.NotFoundHandler())
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, "nosniff", w.Header().Get(HEADER_CONTENT_TYPE_OPTS))
+	assert.Equal(t, "DENY", w.Header().Get(HEADER_FRAME_OPTIONS))
+	assert.Equal(t, "default-src 'self'", w.Header().Get(HEADER_CSP))
+	assert.Empty(t, w.Header().Get(HEADER_HSTS))
+
+	r.Header.Set(HEADER_X_FORWARDED_PROTO, "https")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, "max-age=15552000; includeSubDomains", w.Header().Get(HEADER_HSTS))
+}
+
+func TestRequestID(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get(HEADER_REQUEST_ID)))
+	}))
+	defer upstream.Close()
+
+	apiClient := new(APIClient)
+	handler := RequestID(new(testIDGenerator))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buffer, err := apiClient.DoBufferContext(r.Context(), http.DefaultClient, http.MethodGet, upstream.URL, nil, nil)
+		assert.NoError(t, err)
+		w.Write(buffer.Bytes())
+		RecycleBuffer(buffer)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	requestID := w.Header().Get(HEADER_REQUEST_ID)
+	assert.NotEmpty(t, requestID)
+	assert.Equal(t, requestID, w.Body.String())
+
+	r.Header.Set(HEADER_REQUEST_ID, "abc-123")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, "abc-123", w.Body.String())
+
+	r.Header.Set(HEADER_REQUEST_ID, "bad\nid")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.NotEqual(t, "bad\nid", w.Body.String())
+
+	assert.Equal(t, "x", GetRequestID(WithRequestID(context.Background(), "x")))
+}
@@ -0,0 +1,50 @@
+package shttp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	// _acceptProto 优先接收 protobuf，服务端不支持时接收 JSON
+	_acceptProto = CTYPE_PROTOBUF + ", " + CTYPE_JSON + ";q=0.9"
+)
+
+// protoBody 包装一个 protobuf 消息，按 application/x-protobuf 发送
+type protoBody struct {
+	msg proto.Message
+}
+
+// Proto 把 protobuf 消息按 application/x-protobuf 发送，不使用 Proto 包装的消息仍按 JSON 发送
+func Proto(msg proto.Message) interface{} {
+	return &protoBody{msg: msg}
+}
+
+// GetProto 发送 GET 请求，优先接收 protobuf 响应并解析到 result，服务端返回 JSON 时按 JSON 解析
+func (x *APIClient) GetProto(ctx context.Context, client *http.Client, url string, configRequest func(*http.Request), result proto.Message) error {
+	return x.DoProto(ctx, client, http.MethodGet, url, configRequest, nil, result)
+}
+
+func (x *APIClient) PostProto(ctx context.Context, client *http.Client, url string, configRequest func(*http.Request), bodyObj, result proto.Message) error {
+	return x.DoProto(ctx, client, http.MethodPost, url, configRequest, bodyObj, result)
+}
+
+func (x *APIClient) PutProto(ctx context.Context, client *http.Client, url string, configRequest func(*http.Request), bodyObj, result proto.Message) error {
+	return x.DoProto(ctx, client, http.MethodPut, url, configRequest, bodyObj, result)
+}
+
+// DoProto 以 protobuf 发送 bodyObj（可以为 nil），根据响应的 Content-Type 解析到 result，result 为 nil 时丢弃响应体，
+// 非 2xx 响应返回 *HTTPError
+func (x *APIClient) DoProto(ctx context.Context, client *http.Client, method, url string, configRequest func(*http.Request), bodyObj, result proto.Message) error {
+	var body interface{}
+	if bodyObj != nil {
+		body = Proto(bodyObj)
+	}
+	var r interface{}
+	if result != nil {
+		r = result
+	}
+	return x.doResult(ctx, client, method, url, _acceptProto, configRequest, body, r)
+}
@@ -0,0 +1,76 @@
+package shttp
+
+import (
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/syncfuture/go/sproto"
+)
+
+func TestAPIClient_DoProto(t *testing.T) {
+	var contentTypes, encodings []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentTypes = append(contentTypes, r.Header.Get(HEADER_CTYPE))
+		encodings = append(encodings, r.Header.Get(HEADER_CONTENT_ENCODING))
+		route := new(sproto.RouteDTO)
+		if err := Bind(r, route); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		route.Action = "saved"
+
+		if r.URL.Path == "/gzip" {
+			w.Header().Set(HEADER_CTYPE, CTYPE_JSON)
+			w.Header().Set(HEADER_CONTENT_ENCODING, "gzip")
+			gw := gzip.NewWriter(w)
+			gw.Write([]byte(`{"ID":"` + route.ID + `","Action":"gzipped"}`))
+			gw.Close()
+			return
+		}
+		WriteResult(w, r, http.StatusOK, route)
+	}))
+	defer server.Close()
+
+	apiClient := &APIClient{GzipMinSize: 20}
+	ctx := context.Background()
+
+	// protobuf 请求和响应，请求体超过 GzipMinSize 时压缩
+	result := new(sproto.RouteDTO)
+	err := apiClient.PostProto(ctx, http.DefaultClient, server.URL, nil, &sproto.RouteDTO{ID: "r1", Area: "api", Controller: "usermanagement"}, result)
+	assert.NoError(t, err)
+	assert.Equal(t, "r1", result.ID)
+	assert.Equal(t, "api", result.Area)
+	assert.Equal(t, "saved", result.Action)
+	assert.Equal(t, CTYPE_PROTOBUF, contentTypes[0])
+	assert.Equal(t, "gzip", encodings[0])
+
+	// 没有用 Proto 包装的消息仍按 JSON 发送，JSON 响应可以解析到 proto.Message
+	result = new(sproto.RouteDTO)
+	err = apiClient.PostJSON(ctx, http.DefaultClient, server.URL, nil, &sproto.RouteDTO{ID: "r2"}, result)
+	assert.NoError(t, err)
+	assert.Equal(t, "r2", result.ID)
+	assert.Equal(t, CTYPE_JSON, contentTypes[1])
+	assert.Equal(t, "", encodings[1])
+
+	// Content-Type 为 protobuf 时，没有用 Proto 包装的消息也按 protobuf 编码
+	result = new(sproto.RouteDTO)
+	err = apiClient.PostJSON(ctx, http.DefaultClient, server.URL, func(r *http.Request) {
+		r.Header.Set(HEADER_CTYPE, CTYPE_PROTOBUF)
+	}, &sproto.RouteDTO{ID: "r4"}, result)
+	assert.NoError(t, err)
+	assert.Equal(t, "r4", result.ID)
+	assert.Equal(t, CTYPE_PROTOBUF, contentTypes[2])
+
+	// 调用方自己设置 Accept-Encoding 时 Transport 不会解压，由 APIClient 解压
+	result = new(sproto.RouteDTO)
+	err = apiClient.DoProto(ctx, http.DefaultClient, http.MethodPost, server.URL+"/gzip", func(r *http.Request) {
+		r.Header.Set("Accept-Encoding", "gzip")
+	}, &sproto.RouteDTO{ID: "r3"}, result)
+	assert.NoError(t, err)
+	assert.Equal(t, "r3", result.ID)
+	assert.Equal(t, "gzipped", result.Action)
+}
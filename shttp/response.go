@@ -0,0 +1,181 @@
+package shttp
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/syncfuture/go/serr"
+	log "github.com/syncfuture/go/slog"
+	"github.com/syncfuture/go/sproto"
+)
+
+const (
+	// StatusClientClosedRequest 客户端在响应前断开连接（与 nginx 的 499 一致）
+	StatusClientClosedRequest = 499
+)
+
+var (
+	_errorStatusLock sync.RWMutex
+	_errorStatuses   = make(map[string]int)
+)
+
+// RegisterErrorStatus 指定错误码（serr.NewCode/WithCode）对应的 HTTP 状态码，例如 E_NOT_FOUND -> 404
+func RegisterErrorStatus(code string, statusCode int) {
+	_errorStatusLock.Lock()
+	defer _errorStatusLock.Unlock()
+	_errorStatuses[code] = statusCode
+}
+
+// ErrorStatus 返回错误对应的 HTTP 状态码：
+// 错误链上实现了 HTTPStatus() int 的错误（如 *BindError）优先，其次是 RegisterErrorStatus 注册的错误码，
+// 下游返回的 *HTTPError 没有注册错误码时返回 502（下游的 401、403 不代表调用方的身份有问题），
+// 熔断和限流返回 503，超时返回 504，其余为 500
+func ErrorStatus(err error) int {
+	var statusErr interface{ HTTPStatus() int }
+	if serr.As(err, &statusErr) {
+		return statusErr.HTTPStatus()
+	}
+
+	if code := serr.GetCode(err); code != "" {
+		if statusCode, ok := registeredStatus(code); ok {
+			return statusCode
+		}
+	}
+
+	var httpErr *HTTPError
+	var breakerErr *BreakerOpenError
+	var rateLimitErr *RateLimitError
+	switch {
+	case serr.As(err, &httpErr):
+		return http.StatusBadGateway
+	case serr.As(err, &breakerErr), serr.As(err, &rateLimitErr):
+		return http.StatusServiceUnavailable
+	case serr.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case serr.Is(err, context.Canceled):
+		return StatusClientClosedRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func registeredStatus(code string) (int, bool) {
+	_errorStatusLock.RLock()
+	defer _errorStatusLock.RUnlock()
+	statusCode, ok := _errorStatuses[code]
+	return statusCode, ok
+}
+
+// clientCode 返回可以写给客户端的错误码，下游 *HTTPError 的错误码只有注册过时才使用
+func clientCode(err error) string {
+	code := serr.GetCode(err)
+	if code == "" {
+		return ""
+	}
+	var httpErr *HTTPError
+	if serr.As(err, &httpErr) && httpErr.MsgCode == code {
+		if _, ok := registeredStatus(code); !ok {
+			return ""
+		}
+	}
+	return code
+}
+
+// WriteJSON 使用池中的 buffer 编码 v 并写入响应
+func WriteJSON(w http.ResponseWriter, statusCode int, v interface{}) error {
+	buffer := _bufferPool.GetBuffer()
+	defer _bufferPool.PutBuffer(buffer)
+
+	err := json.NewEncoder(buffer).Encode(v)
+	if err != nil {
+		return serr.WithStack(err)
+	}
+	buffer.Truncate(buffer.Len() - 1)
+
+	return writeBody(w, statusCode, CTYPE_JSON+"; "+CHARSET_UTF8, buffer.Bytes())
+}
+
+// WriteProto 使用池中的 buffer 编码 protobuf 消息并写入响应
+func WriteProto(w http.ResponseWriter, statusCode int, msg proto.Message) error {
+	buffer := _bufferPool.GetBuffer()
+	defer _bufferPool.PutBuffer(buffer)
+
+	pb := proto.NewBuffer(buffer.Bytes()[:0])
+	err := pb.Marshal(msg)
+	if err != nil {
+		return serr.WithStack(err)
+	}
+
+	return writeBody(w, statusCode, CTYPE_PROTOBUF, pb.Bytes())
+}
+
+// WriteError 根据 ErrorStatus 写入状态码和 sproto.MsgCodeResult，请求的 Accept 为 protobuf 时以 protobuf 返回。
+// MsgCode 优先使用错误码，本地的 4xx 错误没有错误码时使用错误消息，
+// 下游的 *HTTPError 和 5xx 不向客户端暴露错误细节，只返回状态码对应的通用消息
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	statusCode := ErrorStatus(err)
+
+	var httpErr *HTTPError
+	result := &sproto.MsgCodeResult{MsgCode: clientCode(err)}
+	if result.MsgCode == "" {
+		if statusCode < http.StatusInternalServerError && !serr.As(err, &httpErr) {
+			result.MsgCode = err.Error()
+		} else {
+			result.MsgCode = http.StatusText(statusCode)
+		}
+	}
+
+	if statusCode >= http.StatusInternalServerError {
+		log.Errorf("%s %s [%d] %+v", r.Method, r.URL.String(), statusCode, err)
+	} else {
+		log.Warnf("%s %s [%d] %v", r.Method, r.URL.String(), statusCode, err)
+	}
+
+	var breakerErr *BreakerOpenError
+	var rateLimitErr *RateLimitError
+	if serr.As(err, &breakerErr) && breakerErr.CoolDownLeft > 0 {
+		w.Header().Set(HEADER_RETRY_AFTER, retryAfterSeconds(breakerErr.CoolDownLeft))
+	} else if serr.As(err, &rateLimitErr) && rateLimitErr.Wait > 0 {
+		w.Header().Set(HEADER_RETRY_AFTER, retryAfterSeconds(rateLimitErr.Wait))
+	}
+
+	WriteResult(w, r, statusCode, result)
+}
+
+// retryAfterSeconds 向上取整到秒，至少为 1，避免客户端立即重试
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Max(1, math.Ceil(d.Seconds()))))
+}
+
+// WriteResult 根据请求的 Accept 以 protobuf 或 JSON 写入响应
+func WriteResult(w http.ResponseWriter, r *http.Request, statusCode int, msg proto.Message) {
+	var err error
+	if AcceptsProto(r) {
+		err = WriteProto(w, statusCode, msg)
+	} else {
+		err = WriteJSON(w, statusCode, msg)
+	}
+	if err != nil {
+		log.Errorf("%s %s write response failed: %+v", r.Method, r.URL.String(), err)
+	}
+}
+
+// AcceptsProto 请求的 Accept 是否要求 protobuf
+func AcceptsProto(r *http.Request) bool {
+	return strings.Contains(r.Header.Get(HEADER_ACCEPT), CTYPE_PROTOBUF)
+}
+
+func writeBody(w http.ResponseWriter, statusCode int, contentType string, body []byte) error {
+	header := w.Header()
+	header.Set(HEADER_CTYPE, contentType)
+	header.Set(HEADER_CONTENT_LENGTH, strconv.Itoa(len(body)))
+	w.WriteHeader(statusCode)
+	_, err := w.Write(body)
+	return serr.WithStack(err)
+}
@@ -0,0 +1,70 @@
+package shttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/syncfuture/go/serr"
+	"github.com/syncfuture/go/sproto"
+)
+
+func TestWriteJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	assert.NoError(t, WriteJSON(w, http.StatusCreated, map[string]string{"a": "b"}))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, CTYPE_JSON+"; "+CHARSET_UTF8, w.Header().Get(HEADER_CTYPE))
+	assert.Equal(t, `{"a":"b"}`, w.Body.String())
+}
+
+func TestWriteError(t *testing.T) {
+	RegisterErrorStatus("E_TEST_NOT_FOUND", http.StatusNotFound)
+	RegisterErrorStatus("E_TEST_CONFLICT", http.StatusConflict)
+
+	tests := []struct {
+		err        error
+		statusCode int
+		msgCode    string
+	}{
+		{serr.NewCode("E_TEST_NOT_FOUND", "item not found"), http.StatusNotFound, "E_TEST_NOT_FOUND"},
+		{serr.WithStack(&BindError{Field: "id", Err: serr.New("bad")}), http.StatusBadRequest, "invalid value for 'id': bad"},
+		{serr.WithStack(&HTTPError{StatusCode: http.StatusInternalServerError}), http.StatusBadGateway, "Bad Gateway"},
+		{serr.WithStack(&HTTPError{StatusCode: http.StatusConflict, MsgCode: "E_TEST_CONFLICT"}), http.StatusConflict, "E_TEST_CONFLICT"},
+		// 下游没有注册的错误码、401 和响应体都不会传给调用方
+		{serr.WithStack(&HTTPError{URL: "http://internal/api", StatusCode: http.StatusConflict, MsgCode: "E_CONFLICT"}), http.StatusBadGateway, "Bad Gateway"},
+		{serr.WithStack(&HTTPError{URL: "http://internal/api", StatusCode: http.StatusUnauthorized, Body: []byte("token expired")}), http.StatusBadGateway, "Bad Gateway"},
+		{serr.WithCode(&HTTPError{StatusCode: http.StatusNotFound}, "E_TEST_NOT_FOUND"), http.StatusNotFound, "E_TEST_NOT_FOUND"},
+		{serr.Wrap(context.DeadlineExceeded, "call"), http.StatusGatewayTimeout, "Gateway Timeout"},
+		{serr.New("database password leaked"), http.StatusInternalServerError, "Internal Server Error"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		w := httptest.NewRecorder()
+		WriteError(w, r, test.err)
+		assert.Equal(t, test.statusCode, w.Code, test.err.Error())
+		assert.Equal(t, `{"MsgCode":"`+test.msgCode+`"}`, w.Body.String())
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(HEADER_ACCEPT, CTYPE_PROTOBUF)
+	w := httptest.NewRecorder()
+	WriteError(w, r, serr.NewCode("E_TEST_NOT_FOUND", "item not found"))
+	assert.Equal(t, CTYPE_PROTOBUF, w.Header().Get(HEADER_CTYPE))
+	result := new(sproto.MsgCodeResult)
+	assert.NoError(t, proto.Unmarshal(w.Body.Bytes(), result))
+	assert.Equal(t, "E_TEST_NOT_FOUND", result.MsgCode)
+
+	// 不足一秒的等待时间向上取整
+	for _, err := range []error{&BreakerOpenError{CoolDownLeft: 100 * time.Millisecond}, &RateLimitError{Wait: 1200 * time.Millisecond}} {
+		w = httptest.NewRecorder()
+		WriteError(w, httptest.NewRequest(http.MethodGet, "/", nil), serr.WithStack(err))
+		assert.NotEqual(t, "0", w.Header().Get(HEADER_RETRY_AFTER))
+	}
+	assert.Equal(t, "1", retryAfterSeconds(100*time.Millisecond))
+	assert.Equal(t, "2", retryAfterSeconds(1200*time.Millisecond))
+}
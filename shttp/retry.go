@@ -0,0 +1,118 @@
+package shttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/syncfuture/go/serr"
+	log "github.com/syncfuture/go/slog"
+)
+
+const (
+	HEADER_IDEMPOTENCY_KEY = "Idempotency-Key"
+)
+
+type retryPolicyKey struct{}
+
+// WithRetryPolicy 为单次调用指定重试策略，覆盖 APIClient.RetryPolicy，policy 为 nil 表示不重试
+func WithRetryPolicy(ctx context.Context, policy *serr.RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, policy)
+}
+
+func getRetryPolicy(ctx context.Context, defaultPolicy *serr.RetryPolicy) *serr.RetryPolicy {
+	if v, ok := ctx.Value(retryPolicyKey{}).(*serr.RetryPolicy); ok {
+		return v
+	}
+	return defaultPolicy
+}
+
+// IsIdempotentRequest GET、HEAD、OPTIONS、TRACE、PUT、DELETE 以及带 Idempotency-Key 头的请求可以重试
+func IsIdempotentRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return r.Header.Get(HEADER_IDEMPOTENCY_KEY) != ""
+}
+
+// doWithRetry 按重试策略发送请求，网络错误和 429/502/503/504 响应会重试，
+// 重试次数用尽时返回最后一次的响应而不是错误，与不重试时的行为保持一致
+func (x *APIClient) doWithRetry(ctx context.Context, request *http.Request, send Invoker) (*http.Response, error) {
+	policy := getRetryPolicy(ctx, x.RetryPolicy)
+	if policy == nil || !x.canRetry(request) {
+		return send(request)
+	}
+
+	p := *policy
+	onRetry := p.OnRetry
+	p.OnRetry = func(attempt int, err error, wait time.Duration) {
+		log.Warnf("%s %s retry #%d in %s: %v", request.Method, request.URL.String(), attempt, wait, err)
+		if onRetry != nil {
+			onRetry(attempt, err, wait)
+		}
+	}
+
+	var last *http.Response
+	var statusErr *HTTPError
+	attempt := 0
+	err := serr.Retry(ctx, &p, func(ctx context.Context) error {
+		if last != nil {
+			drainAndClose(last.Body)
+			last = nil
+		}
+
+		attempt++
+		req := request
+		if attempt > 1 {
+			req = request.Clone(ctx)
+			if request.GetBody != nil {
+				body, err := request.GetBody()
+				if err != nil {
+					return serr.Permanent(serr.WithStack(err))
+				}
+				req.Body = body
+			}
+		}
+
+		resp, err := send(req)
+		if err != nil {
+			return err
+		}
+		last = resp
+		if IsRetryableStatus(resp.StatusCode) {
+			statusErr = NewHTTPError(resp, nil, nil)
+			return statusErr
+		}
+		return nil
+	})
+
+	if err == nil || (last != nil && statusErr != nil && serr.Is(err, statusErr)) {
+		return last, nil
+	}
+	if last != nil {
+		drainAndClose(last.Body)
+	}
+	return nil, err
+}
+
+// canRetry 请求是否可以重新发送（重试或者换一个端点），请求体不能重新读取时不可以
+func (x *APIClient) canRetry(request *http.Request) bool {
+	if request.Body != nil && request.Body != http.NoBody && request.GetBody == nil {
+		return false
+	}
+	if x.CanRetry != nil {
+		return x.CanRetry(request)
+	}
+	return IsIdempotentRequest(request)
+}
+
+// drainAndClose 读完并关闭响应体，使连接可以复用
+func drainAndClose(body io.ReadCloser) {
+	if body == nil {
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(body, 64*1024))
+	body.Close()
+}
@@ -0,0 +1,75 @@
+package shttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/syncfuture/go/serr"
+)
+
+func TestAPIClient_Retry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		body, _ := io.ReadAll(r.Body)
+		if n < 3 {
+			w.Header().Set(HEADER_RETRY_AFTER, "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	apiClient := &APIClient{
+		RetryPolicy: &serr.RetryPolicy{
+			MaxAttempts:     3,
+			InitialInterval: time.Millisecond,
+		},
+	}
+	ctx := context.Background()
+
+	// PUT 是幂等的，请求体在重试时可以重放
+	buffer, err := apiClient.DoBufferContext(ctx, http.DefaultClient, "PUT", server.URL, nil, "replay")
+	assert.NoError(t, err)
+	assert.Equal(t, "replay", buffer.String())
+	assert.Equal(t, int32(3), calls)
+	RecycleBuffer(buffer)
+
+	// POST 不是幂等的，不重试
+	atomic.StoreInt32(&calls, 0)
+	resp, err := apiClient.DoContext(ctx, http.DefaultClient, "POST", server.URL, nil, "once")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), calls)
+
+	// 带 Idempotency-Key 的 POST 可以重试
+	atomic.StoreInt32(&calls, 0)
+	err = apiClient.PostJSON(ctx, http.DefaultClient, server.URL, func(r *http.Request) {
+		r.Header.Set(HEADER_IDEMPOTENCY_KEY, "abc")
+	}, map[string]int{"a": 1}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), calls)
+
+	// 次数用尽时返回最后一次的响应
+	atomic.StoreInt32(&calls, -10)
+	resp, err = apiClient.DoContext(ctx, http.DefaultClient, "GET", server.URL, nil, nil)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(-7), calls)
+
+	// 单次调用关闭重试
+	atomic.StoreInt32(&calls, 0)
+	resp, err = apiClient.DoContext(WithRetryPolicy(ctx, nil), http.DefaultClient, "GET", server.URL, nil, nil)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(1), calls)
+}
@@ -0,0 +1,88 @@
+package shttp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HEADER_HSTS              = "Strict-Transport-Security"
+	HEADER_CSP               = "Content-Security-Policy"
+	HEADER_FRAME_OPTIONS     = "X-Frame-Options"
+	HEADER_CONTENT_TYPE_OPTS = "X-Content-Type-Options"
+	HEADER_REFERRER_POLICY   = "Referrer-Policy"
+	HEADER_X_FORWARDED_PROTO = "X-Forwarded-Proto"
+)
+
+type SecurityHeadersConfig struct {
+	// HSTSMaxAge 默认 180 天，小于 0 表示不设置 Strict-Transport-Security，只在 HTTPS 请求（包括 X-Forwarded-Proto: https）上设置
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	// ContentSecurityPolicy 为空时不设置
+	ContentSecurityPolicy string
+	// FrameOptions 默认 DENY，"-" 表示不设置
+	FrameOptions string
+	// ReferrerPolicy 默认 strict-origin-when-cross-origin，"-" 表示不设置
+	ReferrerPolicy string
+}
+
+// SecurityHeaders 设置 HSTS、CSP、X-Frame-Options、X-Content-Type-Options 和 Referrer-Policy 响应头，
+// 在调用 handler 之前设置，handler 可以覆盖
+func SecurityHeaders(config *SecurityHeadersConfig) Middleware {
+	var c SecurityHeadersConfig
+	if config != nil {
+		c = *config
+	}
+	if c.HSTSMaxAge == 0 {
+		c.HSTSMaxAge = 180 * 24 * time.Hour
+	}
+	if c.FrameOptions == "" {
+		c.FrameOptions = "DENY"
+	}
+	if c.ReferrerPolicy == "" {
+		c.ReferrerPolicy = "strict-origin-when-cross-origin"
+	}
+
+	var hsts string
+	if c.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(int64(c.HSTSMaxAge/time.Second), 10)
+		if c.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if c.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	headers := map[string]string{
+		HEADER_CONTENT_TYPE_OPTS: "nosniff",
+		HEADER_CSP:               c.ContentSecurityPolicy,
+		HEADER_FRAME_OPTIONS:     c.FrameOptions,
+		HEADER_REFERRER_POLICY:   c.ReferrerPolicy,
+	}
+	for k, v := range headers {
+		if v == "" || v == "-" {
+			delete(headers, k)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := w.Header()
+			for k, v := range headers {
+				header.Set(k, v)
+			}
+			if hsts != "" && isHTTPS(r) {
+				header.Set(HEADER_HSTS, hsts)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get(HEADER_X_FORWARDED_PROTO), "https")
+}
@@ -1,16 +1,21 @@
 package shttp
 
 import (
+	"context"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/syncfuture/go/serr"
 )
 
 func TestAPIClient_Do(t *testing.T) {
 	apiClient := new(APIClient)
-	resp, err := apiClient.Do(http.DefaultClient, "GET", "https://www.google.com", nil, nil)
+	client := NewReplayTransport("testdata/google.json", ReplayModeReplay).Client()
+	resp, err := apiClient.Do(client, "GET", "https://www.google.com", nil, nil)
 	assert.NoError(t, err)
 	body, err := io.ReadAll(resp.Body)
 	defer resp.Body.Close()
@@ -20,8 +25,153 @@ func TestAPIClient_Do(t *testing.T) {
 
 func TestAPIClient_Do1(t *testing.T) {
 	apiClient := new(APIClient)
-	buffer, err := apiClient.DoBuffer(http.DefaultClient, "GET", "https://www.google.com", nil, nil)
+	client := NewReplayTransport("testdata/google.json", ReplayModeReplay).Client()
+	buffer, err := apiClient.DoBuffer(client, "GET", "https://www.google.com", nil, nil)
 	assert.NoError(t, err)
 	t.Log(buffer.String())
 	RecycleBuffer(buffer)
 }
+
+func TestAPIClient_DoContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	apiClient := &APIClient{Timeout: 50 * time.Millisecond}
+
+	buffer, err := apiClient.DoBufferContext(context.Background(), http.DefaultClient, "POST", server.URL, nil, map[string]string{"a": "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":"b"}`, buffer.String())
+	RecycleBuffer(buffer)
+
+	_, err = apiClient.DoBufferContext(context.Background(), http.DefaultClient, "POST", server.URL+"/slow", nil, "abc")
+	assert.True(t, serr.Is(err, context.DeadlineExceeded))
+
+	ctx := WithTimeout(context.Background(), time.Second)
+	buffer, err = apiClient.DoBufferContext(ctx, http.DefaultClient, "POST", server.URL+"/slow", nil, "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", buffer.String())
+	RecycleBuffer(buffer)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = apiClient.DoContext(ctx, http.DefaultClient, "POST", server.URL, nil, "abc")
+	assert.True(t, serr.Is(err, context.Canceled))
+}
+
+func TestAPIClient_DoJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HEADER_CTYPE, CTYPE_JSON)
+		switch r.URL.Path {
+		case "/item":
+			w.Write([]byte(`{"ID":"1","Name":"test"}`))
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"MsgCode":"E_NOT_FOUND"}`))
+		case "/busy":
+			w.Header().Set(HEADER_RETRY_AFTER, "2")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	apiClient := new(APIClient)
+	ctx := context.Background()
+
+	var item struct {
+		ID   string
+		Name string
+	}
+	err := apiClient.GetJSON(ctx, http.DefaultClient, server.URL+"/item", nil, &item)
+	assert.NoError(t, err)
+	assert.Equal(t, "test", item.Name)
+
+	err = apiClient.PostJSON(ctx, http.DefaultClient, server.URL+"/missing", nil, item, nil)
+	var httpErr *HTTPError
+	assert.True(t, serr.As(err, &httpErr))
+	assert.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+	assert.Equal(t, "POST", httpErr.Method)
+	assert.Equal(t, "E_NOT_FOUND", serr.GetCode(err))
+	assert.False(t, serr.IsRetryable(err))
+
+	err = apiClient.DeleteJSON(ctx, http.DefaultClient, server.URL+"/busy", nil, nil)
+	assert.True(t, serr.IsRetryable(err))
+	d, _ := serr.GetRetryAfter(err)
+	assert.Equal(t, 2*time.Second, d)
+}
+
+func TestAPIClient_Interceptors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Auth", r.Header.Get(HEADER_AUTH))
+		w.Header().Set("X-ID", r.Header.Get(HEADER_REQUEST_ID))
+	}))
+	defer server.Close()
+
+	var order []string
+	trace := func(name string) Interceptor {
+		return func(r *http.Request, next Invoker) (*http.Response, error) {
+			order = append(order, name+">")
+			resp, err := next(r)
+			order = append(order, "<"+name)
+			return resp, err
+		}
+	}
+
+	apiClient := &APIClient{
+		Interceptors: []Interceptor{
+			trace("a"),
+			BearerInterceptor(func(ctx context.Context) (string, error) { return "token", nil }),
+			RequestIDInterceptor(new(testIDGenerator)),
+			LoggingInterceptor(true),
+		},
+	}
+
+	ctx := WithInterceptors(context.Background(), trace("b"))
+	resp, err := apiClient.DoContext(ctx, http.DefaultClient, "GET", server.URL, nil, nil)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "Bearer token", resp.Header.Get("X-Auth"))
+	assert.Equal(t, "test-id", resp.Header.Get("X-ID"))
+	assert.Equal(t, []string{"a>", "b>", "<b", "<a"}, order)
+
+	redacted := RedactHeaders(http.Header{HEADER_AUTH: {"Bearer token"}, "Accept": {CTYPE_JSON}})
+	assert.Equal(t, "***", redacted.Get(HEADER_AUTH))
+	assert.Equal(t, CTYPE_JSON, redacted.Get("Accept"))
+}
+
+type testIDGenerator struct{}
+
+func (x *testIDGenerator) GenerateString() string {
+	return "test-id"
+}
+
+func TestGetRespBuffer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.Header().Set(HEADER_CTYPE, CTYPE_JSON)
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"MsgCode":"E_NOT_FOUND"}`))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	buffer, err := GetRespBuffer(http.Get(server.URL))
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", buffer.String())
+	RecycleBuffer(buffer)
+
+	buffer, err = GetRespBuffer(http.Get(server.URL + "/missing"))
+	assert.Nil(t, buffer)
+	var httpErr *HTTPError
+	assert.True(t, serr.As(err, &httpErr))
+	assert.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+	assert.Equal(t, "E_NOT_FOUND", httpErr.MsgCode)
+	assert.Equal(t, `{"MsgCode":"E_NOT_FOUND"}`, string(httpErr.Body))
+}
@@ -0,0 +1,344 @@
+package shttp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syncfuture/go/serr"
+	log "github.com/syncfuture/go/slog"
+	"github.com/syncfuture/go/sproto"
+)
+
+const (
+	// HEADER_SIGNATURE 格式为 keyId=k1,timestamp=1617000000,nonce=...,signature=...
+	HEADER_SIGNATURE          = "X-Signature"
+	MSGCODE_INVALID_SIGNATURE = "E_INVALID_SIGNATURE"
+)
+
+// SigningKeys 签名密钥，签名使用当前密钥，验证时接受全部密钥。
+// 轮换时先在验证方 Add 新密钥，再在签名方 Rotate，最后 Remove 旧密钥
+type SigningKeys struct {
+	lock    sync.RWMutex
+	current string
+	keys    map[string][]byte
+}
+
+func NewSigningKeys(currentKeyID string, keys map[string][]byte) *SigningKeys {
+	r := &SigningKeys{
+		current: currentKeyID,
+		keys:    make(map[string][]byte, len(keys)),
+	}
+	for id, key := range keys {
+		r.keys[id] = key
+	}
+	if _, ok := r.keys[currentKeyID]; !ok && currentKeyID != "" {
+		log.Fatalf("signing key %s does not exist", currentKeyID)
+	}
+	return r
+}
+
+// Add 添加密钥，不改变当前密钥
+func (x *SigningKeys) Add(keyID string, key []byte) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.keys[keyID] = key
+}
+
+// Rotate 添加密钥并设为当前密钥
+func (x *SigningKeys) Rotate(keyID string, key []byte) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.keys[keyID] = key
+	x.current = keyID
+}
+
+func (x *SigningKeys) Remove(keyID string) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	delete(x.keys, keyID)
+}
+
+func (x *SigningKeys) Current() (keyID string, key []byte) {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	return x.current, x.keys[x.current]
+}
+
+func (x *SigningKeys) Get(keyID string) ([]byte, bool) {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	key, ok := x.keys[keyID]
+	return key, ok
+}
+
+// SignatureError 签名验证失败的原因
+type SignatureError struct {
+	Reason string
+}
+
+func (x *SignatureError) Error() string {
+	return "invalid signature: " + x.Reason
+}
+
+func (x *SignatureError) HTTPStatus() int {
+	return http.StatusUnauthorized
+}
+
+func (x *SignatureError) Code() string {
+	return MSGCODE_INVALID_SIGNATURE
+}
+
+// SignerInterceptor 使用 keys 的当前密钥为请求签名，每次重试重新生成时间戳和 nonce
+func SignerInterceptor(keys *SigningKeys) Interceptor {
+	return func(request *http.Request, next Invoker) (*http.Response, error) {
+		keyID, key := keys.Current()
+		err := SignRequest(request, keyID, key)
+		if err != nil {
+			return nil, err
+		}
+		return next(request)
+	}
+}
+
+// SignRequest 对方法、路径、规范化的查询字符串、请求体的 SHA-256 和时间戳、nonce 签名，结果写入 X-Signature 头
+func SignRequest(request *http.Request, keyID string, key []byte) error {
+	digest, err := requestBodyDigest(request)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, 16)
+	if _, err = rand.Read(nonce); err != nil {
+		return serr.WithStack(err)
+	}
+	params := &signatureParams{
+		KeyID:     keyID,
+		Timestamp: time.Now().Unix(),
+		Nonce:     hex.EncodeToString(nonce),
+	}
+	params.Signature = computeSignature(key, request, digest, params)
+	request.Header.Set(HEADER_SIGNATURE, params.String())
+	return nil
+}
+
+type SignatureVerifierConfig struct {
+	Keys *SigningKeys
+	// ClockSkew 允许的时间误差，默认 5 分钟
+	ClockSkew time.Duration
+	// NonceCache 用于拒绝重放，默认使用内存缓存，多实例部署时使用 NewRedisNonceCache
+	NonceCache INonceCache
+	// MaxBodySize 读取请求体的最大长度，默认 MaxBindBodySize
+	MaxBodySize int64
+}
+
+// VerifySignature 验证 SignerInterceptor 签名的请求，失败返回 401 和 sproto.MsgCodeResult，原因记录在日志中，
+// 请求体超过 MaxBodySize 时返回 413
+func VerifySignature(config *SignatureVerifierConfig) Middleware {
+	c := *config
+	if c.Keys == nil {
+		log.Fatal("keys cannot be nil")
+	}
+	if c.ClockSkew <= 0 {
+		c.ClockSkew = 5 * time.Minute
+	}
+	if c.NonceCache == nil {
+		c.NonceCache = NewMemoryNonceCache()
+	}
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = MaxBindBodySize
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			err := c.verify(r)
+			if serr.Is(err, ErrBodyTooLarge) {
+				WriteError(w, r, err)
+				return
+			}
+			if err != nil {
+				log.Warnf("%s %s signature rejected: %v", r.Method, r.URL.Path, err)
+				WriteResult(w, r, http.StatusUnauthorized, &sproto.MsgCodeResult{MsgCode: MSGCODE_INVALID_SIGNATURE})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (x *SignatureVerifierConfig) verify(r *http.Request) error {
+	// 先检查请求体的长度，过大的请求体与 Bind 一样返回 413，而不是签名错误
+	if r.ContentLength > x.MaxBodySize {
+		return serr.WithStack(&BindError{Err: ErrBodyTooLarge})
+	}
+	// 读取请求体计算摘要后恢复，handler 可以再次读取
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(r.Body, x.MaxBodySize+1))
+		r.Body.Close()
+		if err != nil {
+			return serr.WithStack(err)
+		}
+		if int64(len(body)) > x.MaxBodySize {
+			return serr.WithStack(&BindError{Err: ErrBodyTooLarge})
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	params, err := parseSignatureParams(r.Header.Get(HEADER_SIGNATURE))
+	if err != nil {
+		return err
+	}
+
+	key, ok := x.Keys.Get(params.KeyID)
+	if !ok {
+		return &SignatureError{Reason: fmt.Sprintf("unknown key id '%s'", params.KeyID)}
+	}
+
+	skew := time.Since(time.Unix(params.Timestamp, 0))
+	if skew > x.ClockSkew || skew < -x.ClockSkew {
+		return &SignatureError{Reason: fmt.Sprintf("timestamp out of range by %s", skew.Round(time.Second))}
+	}
+
+	sum := sha256.Sum256(body)
+
+	expected := computeSignature(key, r, hex.EncodeToString(sum[:]), params)
+	if !hmac.Equal([]byte(expected), []byte(params.Signature)) {
+		return &SignatureError{Reason: "signature mismatch"}
+	}
+
+	// 签名通过后再记录 nonce，伪造的请求不能占用 nonce
+	first, err := x.NonceCache.Add(params.KeyID+":"+params.Nonce, 2*x.ClockSkew)
+	if err != nil {
+		return err
+	}
+	if !first {
+		return &SignatureError{Reason: "replayed nonce"}
+	}
+	return nil
+}
+
+type signatureParams struct {
+	KeyID     string
+	Timestamp int64
+	Nonce     string
+	Signature string
+}
+
+func (x *signatureParams) String() string {
+	return "keyId=" + x.KeyID + ",timestamp=" + strconv.FormatInt(x.Timestamp, 10) + ",nonce=" + x.Nonce + ",signature=" + x.Signature
+}
+
+func parseSignatureParams(v string) (*signatureParams, error) {
+	if v == "" {
+		return nil, &SignatureError{Reason: "missing " + HEADER_SIGNATURE + " header"}
+	}
+
+	r := new(signatureParams)
+	for _, part := range strings.Split(v, ",") {
+		i := strings.Index(part, "=")
+		if i < 0 {
+			continue
+		}
+		name, value := strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:])
+		switch name {
+		case "keyId":
+			r.KeyID = value
+		case "timestamp":
+			r.Timestamp, _ = strconv.ParseInt(value, 10, 64)
+		case "nonce":
+			r.Nonce = value
+		case "signature":
+			r.Signature = value
+		}
+	}
+	if r.KeyID == "" || r.Timestamp == 0 || r.Nonce == "" || r.Signature == "" {
+		return nil, &SignatureError{Reason: "malformed " + HEADER_SIGNATURE + " header"}
+	}
+	return r, nil
+}
+
+func computeSignature(key []byte, request *http.Request, bodyDigest string, params *signatureParams) string {
+	mac := hmac.New(sha256.New, key)
+	path := request.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	for _, s := range []string{
+		request.Method,
+		path,
+		canonicalQuery(request.URL.Query()),
+		bodyDigest,
+		strconv.FormatInt(params.Timestamp, 10),
+		params.Nonce,
+		params.KeyID,
+	} {
+		mac.Write([]byte(s))
+		mac.Write([]byte{'\n'})
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// canonicalQuery 按名称和值排序后编码，参数顺序不影响签名
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		vs := append([]string(nil), values[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			if sb.Len() > 0 {
+				sb.WriteByte('&')
+			}
+			sb.WriteString(url.QueryEscape(k))
+			sb.WriteByte('=')
+			sb.WriteString(url.QueryEscape(v))
+		}
+	}
+	return sb.String()
+}
+
+// requestBodyDigest 返回请求体 SHA-256 的十六进制，优先使用 GetBody，不会消耗请求体
+func requestBodyDigest(request *http.Request) (string, error) {
+	h := sha256.New()
+	if request.Body != nil && request.Body != http.NoBody {
+		if request.GetBody != nil {
+			body, err := request.GetBody()
+			if err != nil {
+				return "", serr.WithStack(err)
+			}
+			_, err = io.Copy(h, body)
+			body.Close()
+			if err != nil {
+				return "", serr.WithStack(err)
+			}
+		} else {
+			data, err := io.ReadAll(request.Body)
+			request.Body.Close()
+			if err != nil {
+				return "", serr.WithStack(err)
+			}
+			h.Write(data)
+			request.Body = io.NopCloser(bytes.NewReader(data))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
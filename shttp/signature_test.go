@@ -0,0 +1,87 @@
+package shttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignature(t *testing.T) {
+	verifierKeys := NewSigningKeys("k1", map[string][]byte{"k1": []byte("secret1")})
+	server := httptest.NewServer(VerifySignature(&SignatureVerifierConfig{Keys: verifierKeys, ClockSkew: time.Minute})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	})))
+	defer server.Close()
+
+	signerKeys := NewSigningKeys("k1", map[string][]byte{"k1": []byte("secret1")})
+	var lastRequest *http.Request
+	apiClient := &APIClient{Interceptors: []Interceptor{
+		SignerInterceptor(signerKeys),
+		func(request *http.Request, next Invoker) (*http.Response, error) {
+			lastRequest = request
+			return next(request)
+		},
+	}}
+	ctx := context.Background()
+
+	buffer, err := apiClient.DoBufferContext(ctx, http.DefaultClient, http.MethodPost, server.URL+"/hook?b=2&a=1", nil, map[string]string{"a": "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":"b"}`, buffer.String())
+	RecycleBuffer(buffer)
+
+	send := func(method, url, body, signature string) int {
+		r, _ := http.NewRequest(method, url, strings.NewReader(body))
+		r.Header.Set(HEADER_SIGNATURE, signature)
+		resp, err := http.DefaultClient.Do(r)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// 重放
+	signature := lastRequest.Header.Get(HEADER_SIGNATURE)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, server.URL+"/hook?b=2&a=1", `{"a":"b"}`, signature))
+
+	// 篡改请求体、查询参数或者缺少签名
+	r, _ := http.NewRequest(http.MethodPost, server.URL+"/hook?a=1&b=2", strings.NewReader(`{"a":"b"}`))
+	assert.NoError(t, SignRequest(r, "k1", []byte("secret1")))
+	signature = r.Header.Get(HEADER_SIGNATURE)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, server.URL+"/hook?a=1&b=2", `{"a":"c"}`, signature))
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, server.URL+"/hook?a=1&b=3", `{"a":"b"}`, signature))
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, server.URL+"/hook", "", ""))
+	// 查询参数顺序不影响签名
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, server.URL+"/hook?b=2&a=1", `{"a":"b"}`, signature))
+
+	// 超出时间误差
+	params := &signatureParams{KeyID: "k1", Timestamp: time.Now().Add(-2 * time.Minute).Unix(), Nonce: "n1"}
+	r, _ = http.NewRequest(http.MethodGet, server.URL+"/hook", nil)
+	params.Signature = computeSignature([]byte("secret1"), r, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", params)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, server.URL+"/hook", "", params.String()))
+	params.Timestamp = time.Now().Unix()
+	params.Signature = computeSignature([]byte("secret1"), r, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", params)
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, server.URL+"/hook", "", params.String()))
+
+	// 密钥轮换：验证方先添加新密钥，签名方再切换，最后删除旧密钥
+	verifierKeys.Add("k2", []byte("secret2"))
+	signerKeys.Rotate("k2", []byte("secret2"))
+	verifierKeys.Remove("k1")
+	err = apiClient.DoJSON(ctx, http.DefaultClient, http.MethodGet, server.URL, nil, nil, nil)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(lastRequest.Header.Get(HEADER_SIGNATURE), "keyId=k2,"))
+
+	r, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	SignRequest(r, "k1", []byte("secret1"))
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, server.URL, "", r.Header.Get(HEADER_SIGNATURE)))
+
+	// 请求体过大时返回 413，即使没有签名
+	small := httptest.NewServer(VerifySignature(&SignatureVerifierConfig{Keys: verifierKeys, MaxBodySize: 4})(http.NotFoundHandler()))
+	defer small.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, send(http.MethodPost, small.URL, "too large", ""))
+}
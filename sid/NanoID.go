@@ -0,0 +1,97 @@
+package sid
+
+import (
+	"crypto/rand"
+	"math/bits"
+	"strings"
+
+	"github.com/syncfuture/go/serr"
+	log "github.com/syncfuture/go/slog"
+)
+
+const (
+	// NanoIDAlphabet NanoID 默认的 URL 安全字母表
+	NanoIDAlphabet = "_-0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	NanoIDSize     = 21
+)
+
+var _defaultNanoID = newNanoIDGenerator(0, "")
+
+// NanoIDGenerator 生成随机的短 ID，适合放在 URL 中，不包含时间，不能排序
+type NanoIDGenerator struct {
+	size     int
+	alphabet string
+	mask     byte
+	step     int
+}
+
+// NewNanoIDGenerator size 为 0 时使用 NanoIDSize，alphabet 为空时使用 NanoIDAlphabet，字母表的长度为 2 到 256
+func NewNanoIDGenerator(size int, alphabet string) IIDGeneratorE {
+	return newNanoIDGenerator(size, alphabet)
+}
+
+func newNanoIDGenerator(size int, alphabet string) *NanoIDGenerator {
+	if size <= 0 {
+		size = NanoIDSize
+	}
+	if alphabet == "" {
+		alphabet = NanoIDAlphabet
+	}
+	if len(alphabet) < 2 || len(alphabet) > 256 {
+		log.Fatal("alphabet must contain 2 to 256 characters")
+	}
+
+	// 随机字节与 mask 后落在字母表之外的丢弃，避免取模造成的偏差
+	mask := 1<<uint(bits.Len(uint(len(alphabet)-1))) - 1
+	return &NanoIDGenerator{
+		size:     size,
+		alphabet: alphabet,
+		mask:     byte(mask),
+		step:     (16*mask*size/len(alphabet) + 9) / 10,
+	}
+}
+
+func (x *NanoIDGenerator) GenerateString() string {
+	r, err := x.Generate()
+	if err != nil {
+		log.Errorf("generate NanoID failed with %s\n", err)
+	}
+	return r
+}
+
+func (x *NanoIDGenerator) Generate() (string, error) {
+	r := make([]byte, 0, x.size)
+	buf := make([]byte, x.step)
+	for {
+		if _, err := rand.Read(buf); err != nil {
+			return "", serr.WithStack(err)
+		}
+		for _, b := range buf {
+			b &= x.mask
+			if int(b) < len(x.alphabet) {
+				r = append(r, x.alphabet[b])
+				if len(r) == x.size {
+					return string(r), nil
+				}
+			}
+		}
+	}
+}
+
+// Validate 检查 id 的长度和字符是否符合生成器的设置
+func (x *NanoIDGenerator) Validate(id string) error {
+	if len(id) != x.size {
+		return serr.Errorf("invalid NanoID '%s': length must be %d", id, x.size)
+	}
+	for i := 0; i < len(id); i++ {
+		if strings.IndexByte(x.alphabet, id[i]) < 0 {
+			return serr.Errorf("invalid NanoID '%s': unexpected character '%c'", id, id[i])
+		}
+	}
+	return nil
+}
+
+// ValidateNanoID 使用默认的长度和字母表检查 id
+func ValidateNanoID(id string) error {
+	return _defaultNanoID.Validate(id)
+}
@@ -0,0 +1,132 @@
+package sid
+
+import (
+	"time"
+
+	"github.com/syncfuture/go/serr"
+	log "github.com/syncfuture/go/slog"
+)
+
+// crockfordAlphabet Crockford base32 字母表，不包含 I、L、O、U，按 ASCII 排序，编码后的字符串保持原来的顺序
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var _crockfordDecoding [256]byte
+
+func init() {
+	for i := range _crockfordDecoding {
+		_crockfordDecoding[i] = 0xFF
+	}
+	for i := 0; i < len(crockfordAlphabet); i++ {
+		c := crockfordAlphabet[i]
+		_crockfordDecoding[c] = byte(i)
+		_crockfordDecoding[c|0x20] = byte(i)
+	}
+	// Crockford 规定的易混淆字符
+	for _, c := range "Oo" {
+		_crockfordDecoding[c] = 0
+	}
+	for _, c := range "IiLl" {
+		_crockfordDecoding[c] = 1
+	}
+}
+
+// ULID 48 位毫秒时间戳和 80 位随机数，编码为 26 个字符的 Crockford base32，按字符串排序即按时间排序
+type ULID [16]byte
+
+var _ulidMonotonic = newMonotonic(80)
+
+// NewULID 生成 ULID，同一进程中同一毫秒内生成的 ULID 是递增的
+func NewULID() (ULID, error) {
+	var r ULID
+	ms, entropy, err := _ulidMonotonic.next()
+	if err != nil {
+		return r, err
+	}
+	r[0] = byte(ms >> 40)
+	r[1] = byte(ms >> 32)
+	r[2] = byte(ms >> 24)
+	r[3] = byte(ms >> 16)
+	r[4] = byte(ms >> 8)
+	r[5] = byte(ms)
+	copy(r[6:], entropy[:])
+	return r, nil
+}
+
+// ParseULID 解析 ULID，不区分大小写，I、L 视为 1，O 视为 0
+func ParseULID(s string) (ULID, error) {
+	var r ULID
+	// 26 个字符共 130 位，第一个字符不能超过 7
+	if len(s) != 26 || _crockfordDecoding[s[0]] > 7 {
+		return r, serr.Errorf("invalid ULID '%s'", s)
+	}
+
+	var acc uint
+	var bits uint
+	n := 0
+	for i := 0; i < len(s); i++ {
+		v := _crockfordDecoding[s[i]]
+		if v == 0xFF {
+			return r, serr.Errorf("invalid ULID '%s'", s)
+		}
+		acc = acc<<5 | uint(v)
+		bits += 5
+		// 跳过最前面多出的 2 位
+		if i == 0 {
+			bits -= 2
+		}
+		if bits >= 8 {
+			bits -= 8
+			r[n] = byte(acc >> bits)
+			n++
+		}
+	}
+	return r, nil
+}
+
+func (x ULID) Time() time.Time {
+	ms := int64(x[0])<<40 | int64(x[1])<<32 | int64(x[2])<<24 | int64(x[3])<<16 | int64(x[4])<<8 | int64(x[5])
+	return time.Unix(ms/1000, ms%1000*int64(time.Millisecond))
+}
+
+func (x ULID) String() string {
+	var buf [26]byte
+	// 从最低位开始每 5 位一个字符
+	var acc uint
+	var bits uint
+	j := len(buf) - 1
+	for i := len(x) - 1; i >= 0; i-- {
+		acc |= uint(x[i]) << bits
+		bits += 8
+		for bits >= 5 {
+			buf[j] = crockfordAlphabet[acc&0x1F]
+			j--
+			acc >>= 5
+			bits -= 5
+		}
+	}
+	buf[0] = crockfordAlphabet[acc&0x1F]
+	return string(buf[:])
+}
+
+type ULIDGenerator struct{}
+
+// NewULIDGenerator 生成 ULID 的 IIDGeneratorE，适合作为可以排序的公开 ID
+func NewULIDGenerator() IIDGeneratorE {
+	return new(ULIDGenerator)
+}
+
+func (x *ULIDGenerator) GenerateString() string {
+	r, err := x.Generate()
+	if err != nil {
+		log.Errorf("generate ULID failed with %s\n", err)
+	}
+	return r
+}
+
+func (x *ULIDGenerator) Generate() (string, error) {
+	id, err := NewULID()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
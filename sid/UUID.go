@@ -0,0 +1,129 @@
+package sid
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+
+	"github.com/syncfuture/go/serr"
+	log "github.com/syncfuture/go/slog"
+)
+
+// UUID RFC 9562 UUID
+type UUID [16]byte
+
+var _uuidv7Monotonic = newMonotonic(74)
+
+// NewUUIDv4 生成随机的 UUID 版本 4
+func NewUUIDv4() (UUID, error) {
+	var r UUID
+	if _, err := rand.Read(r[:]); err != nil {
+		return r, serr.WithStack(err)
+	}
+	r[6] = r[6]&0x0F | 0x40
+	r[8] = r[8]&0x3F | 0x80
+	return r, nil
+}
+
+// NewUUIDv7 生成以毫秒时间戳开头的 UUID 版本 7，按生成顺序排序，适合作为数据库主键。
+// 同一进程中同一毫秒内生成的 UUID 也是递增的
+func NewUUIDv7() (UUID, error) {
+	var r UUID
+	ms, entropy, err := _uuidv7Monotonic.next()
+	if err != nil {
+		return r, err
+	}
+
+	// 74 位随机数：rand_a 12 位，rand_b 62 位
+	hi := uint64(binary.BigEndian.Uint16(entropy[:2]))
+	lo := binary.BigEndian.Uint64(entropy[2:])
+	randA := hi<<2 | lo>>62
+
+	binary.BigEndian.PutUint64(r[8:], lo)
+	r[8] = r[8]&0x3F | 0x80
+	binary.BigEndian.PutUint16(r[6:8], uint16(0x7000|randA&0x0FFF))
+	r[0] = byte(ms >> 40)
+	r[1] = byte(ms >> 32)
+	r[2] = byte(ms >> 24)
+	r[3] = byte(ms >> 16)
+	r[4] = byte(ms >> 8)
+	r[5] = byte(ms)
+	return r, nil
+}
+
+// ParseUUID 解析 xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx 格式的 UUID，不区分大小写
+func ParseUUID(s string) (UUID, error) {
+	var r UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return r, serr.Errorf("invalid UUID '%s'", s)
+	}
+	src := s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	if _, err := hex.Decode(r[:], []byte(src)); err != nil {
+		return r, serr.Errorf("invalid UUID '%s'", s)
+	}
+	return r, nil
+}
+
+func (x UUID) Version() int {
+	return int(x[6] >> 4)
+}
+
+// Time 返回版本 7 UUID 中的时间，其他版本返回错误
+func (x UUID) Time() (time.Time, error) {
+	if x.Version() != 7 {
+		return time.Time{}, serr.Errorf("UUID version %d has no unix timestamp", x.Version())
+	}
+	ms := int64(x[0])<<40 | int64(x[1])<<32 | int64(x[2])<<24 | int64(x[3])<<16 | int64(x[4])<<8 | int64(x[5])
+	return time.Unix(ms/1000, ms%1000*int64(time.Millisecond)), nil
+}
+
+func (x UUID) String() string {
+	var buf [36]byte
+	hex.Encode(buf[:8], x[:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], x[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], x[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], x[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], x[10:])
+	return string(buf[:])
+}
+
+type UUIDGenerator struct {
+	version int
+}
+
+// NewUUIDv4Generator 生成随机 UUID 的 IIDGeneratorE
+func NewUUIDv4Generator() IIDGeneratorE {
+	return &UUIDGenerator{version: 4}
+}
+
+// NewUUIDv7Generator 生成按时间排序的 UUID 的 IIDGeneratorE
+func NewUUIDv7Generator() IIDGeneratorE {
+	return &UUIDGenerator{version: 7}
+}
+
+func (x *UUIDGenerator) GenerateString() string {
+	r, err := x.Generate()
+	if err != nil {
+		log.Errorf("generate UUID failed with %s\n", err)
+	}
+	return r
+}
+
+func (x *UUIDGenerator) Generate() (string, error) {
+	var id UUID
+	var err error
+	if x.version == 7 {
+		id, err = NewUUIDv7()
+	} else {
+		id, err = NewUUIDv4()
+	}
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
@@ -0,0 +1,118 @@
+package sid
+
+import (
+	"strconv"
+
+	"github.com/syncfuture/go/serr"
+)
+
+// IDEncoding 数字 ID 的字符串编码
+type IDEncoding int
+
+const (
+	// EncodingHex 不补零的十六进制，与之前的 GenerateString 一致，长度不固定，不能按字符串排序
+	EncodingHex IDEncoding = iota
+	// EncodingDecimal 不补零的十进制，长度不固定，不能按字符串排序
+	EncodingDecimal
+	// EncodingBase62 补零到 11 个字符的 base62，按字符串排序即按数值排序
+	EncodingBase62
+	// EncodingBase32 补零到 13 个字符的 Crockford base32，不区分大小写，按字符串排序即按数值排序
+	EncodingBase32
+)
+
+// base62Alphabet 按 ASCII 排序
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var _base62Decoding [256]byte
+
+func init() {
+	for i := range _base62Decoding {
+		_base62Decoding[i] = 0xFF
+	}
+	for i := 0; i < len(base62Alphabet); i++ {
+		_base62Decoding[base62Alphabet[i]] = byte(i)
+	}
+}
+
+func (x IDEncoding) Encode(id uint64) string {
+	switch x {
+	case EncodingDecimal:
+		return strconv.FormatUint(id, 10)
+	case EncodingBase62:
+		return EncodeBase62(id)
+	case EncodingBase32:
+		return EncodeBase32(id)
+	}
+	return strconv.FormatUint(id, 16)
+}
+
+func (x IDEncoding) Decode(s string) (uint64, error) {
+	switch x {
+	case EncodingDecimal:
+		r, err := strconv.ParseUint(s, 10, 64)
+		return r, serr.WithStack(err)
+	case EncodingBase62:
+		return DecodeBase62(s)
+	case EncodingBase32:
+		return DecodeBase32(s)
+	}
+	r, err := strconv.ParseUint(s, 16, 64)
+	return r, serr.WithStack(err)
+}
+
+// EncodeBase62 编码为固定 11 个字符的 base62
+func EncodeBase62(id uint64) string {
+	var buf [11]byte
+	for i := len(buf) - 1; i >= 0; i-- {
+		buf[i] = base62Alphabet[id%62]
+		id /= 62
+	}
+	return string(buf[:])
+}
+
+// DecodeBase62 解码 EncodeBase62 的结果，也接受不补零的字符串
+func DecodeBase62(s string) (uint64, error) {
+	if s == "" || len(s) > 11 {
+		return 0, serr.Errorf("invalid base62 id '%s'", s)
+	}
+	var r uint64
+	for i := 0; i < len(s); i++ {
+		v := _base62Decoding[s[i]]
+		if v == 0xFF {
+			return 0, serr.Errorf("invalid base62 id '%s'", s)
+		}
+		next := r*62 + uint64(v)
+		if r > (1<<64-1)/62 || next < r*62 {
+			return 0, serr.Errorf("base62 id '%s' overflows uint64", s)
+		}
+		r = next
+	}
+	return r, nil
+}
+
+// EncodeBase32 编码为固定 13 个字符的 Crockford base32
+func EncodeBase32(id uint64) string {
+	var buf [13]byte
+	for i := len(buf) - 1; i >= 0; i-- {
+		buf[i] = crockfordAlphabet[id&0x1F]
+		id >>= 5
+	}
+	return string(buf[:])
+}
+
+// DecodeBase32 解码 EncodeBase32 的结果，不区分大小写，I、L 视为 1，O 视为 0，也接受不补零的字符串
+func DecodeBase32(s string) (uint64, error) {
+	// 13 个字符共 65 位，第一个字符不能超过 F
+	if s == "" || len(s) > 13 || (len(s) == 13 && _crockfordDecoding[s[0]] > 15) {
+		return 0, serr.Errorf("invalid base32 id '%s'", s)
+	}
+	var r uint64
+	for i := 0; i < len(s); i++ {
+		v := _crockfordDecoding[s[i]]
+		if v == 0xFF {
+			return 0, serr.Errorf("invalid base32 id '%s'", s)
+		}
+		r = r<<5 | uint64(v)
+	}
+	return r, nil
+}
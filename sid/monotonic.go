@@ -0,0 +1,77 @@
+package sid
+
+import (
+	"crypto/rand"
+	"sync"
+	"time"
+
+	"github.com/syncfuture/go/serr"
+)
+
+// monotonic 生成毫秒时间戳和随机数，同一毫秒内随机数在上一个值上加一，保证生成的 ID 严格递增。
+// 随机数用尽或者时钟回拨时借用下一毫秒
+type monotonic struct {
+	lock    sync.Mutex
+	bits    uint
+	ms      uint64
+	entropy [10]byte
+}
+
+func newMonotonic(bits uint) *monotonic {
+	return &monotonic{bits: bits}
+}
+
+func (x *monotonic) next() (ms uint64, entropy [10]byte, err error) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	now := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	if now <= x.ms && x.increment() {
+		return x.ms, x.entropy, nil
+	}
+	if now <= x.ms {
+		now = x.ms + 1
+	}
+
+	if _, err = rand.Read(x.entropy[:]); err != nil {
+		return 0, entropy, serr.WithStack(err)
+	}
+	// 最高位留空，使同一毫秒内可以递增的次数足够多
+	unused := 80 - x.bits + 1
+	for i := 0; unused > 0; i++ {
+		if unused >= 8 {
+			x.entropy[i] = 0
+			unused -= 8
+		} else {
+			x.entropy[i] &= 0xFF >> unused
+			unused = 0
+		}
+	}
+	x.ms = now
+	return x.ms, x.entropy, nil
+}
+
+// increment 随机数加一，溢出 bits 位时返回 false
+func (x *monotonic) increment() bool {
+	carry := true
+	for i := len(x.entropy) - 1; i >= 0 && carry; i-- {
+		x.entropy[i]++
+		carry = x.entropy[i] == 0
+	}
+	if carry {
+		return false
+	}
+	// 溢出时最高的 80 - bits 位不再为 0
+	unused := 80 - x.bits
+	for i := 0; unused > 0; i++ {
+		if unused >= 8 {
+			if x.entropy[i] != 0 {
+				return false
+			}
+			unused -= 8
+		} else {
+			return x.entropy[i]>>(8-unused) == 0
+		}
+	}
+	return true
+}
@@ -1,30 +1,157 @@
 package sid
 
 import (
-	"fmt"
+	"hash/fnv"
+	"os"
+	"time"
 
 	"github.com/sony/sonyflake"
+	"github.com/syncfuture/go/serr"
 	log "github.com/syncfuture/go/slog"
 )
 
 type IIDGenerator interface {
+	// GenerateString 生成失败时记录日志并返回无效的 ID，需要处理错误时使用 IIDGeneratorE
 	GenerateString() string
 }
 
+// IIDGeneratorE 可以返回错误的 IIDGenerator，本包的生成器都实现了这个接口，
+// 接收 IIDGenerator 的地方可以通过类型断言使用 Generate
+type IIDGeneratorE interface {
+	IIDGenerator
+	Generate() (string, error)
+}
+
+var (
+	// SonyflakeStartTime sonyflake 默认的时间起点
+	SonyflakeStartTime = time.Date(2014, 9, 1, 0, 0, 0, 0, time.UTC)
+)
+
+type SonyflakeConfig struct {
+	// StartTime 时间的起点，默认 SonyflakeStartTime，修改后之前生成的 ID 不能正确分解
+	StartTime time.Time
+	// MachineID 默认使用私有 IPv4 地址的低 16 位，取不到时（比如在容器中）使用主机名的哈希
+	MachineID func() (uint16, error)
+	// Encoding GenerateString 和 Generate 使用的编码，默认 EncodingHex
+	Encoding IDEncoding
+}
+
+// SonyflakeParts sonyflake ID 的组成部分
+type SonyflakeParts struct {
+	ID        uint64
+	Time      time.Time
+	Sequence  uint16
+	MachineID uint16
+}
+
 type SonyflakeIDGenerator struct {
 	generator *sonyflake.Sonyflake
+	startTime time.Time
+	encoding  IDEncoding
 }
 
+// NewSonyflakeIDGenerator 使用默认配置，创建失败时记录日志，之后的 Generate 都返回错误，
+// 返回值是 *SonyflakeIDGenerator，可以断言为 IIDGeneratorE
 func NewSonyflakeIDGenerator() IIDGenerator {
-	return &SonyflakeIDGenerator{
-		generator: sonyflake.NewSonyflake(sonyflake.Settings{}),
+	r, err := NewSonyflakeIDGeneratorWithConfig(nil)
+	if err != nil {
+		log.Error(err)
+	}
+	return r
+}
+
+func NewSonyflakeIDGeneratorWithConfig(config *SonyflakeConfig) (*SonyflakeIDGenerator, error) {
+	var c SonyflakeConfig
+	if config != nil {
+		c = *config
+	}
+	if c.StartTime.IsZero() {
+		c.StartTime = SonyflakeStartTime
+	}
+
+	r := &SonyflakeIDGenerator{
+		startTime: c.StartTime,
+		encoding:  c.Encoding,
+	}
+	settings := sonyflake.Settings{
+		StartTime: c.StartTime,
+		MachineID: c.MachineID,
+	}
+	r.generator = sonyflake.NewSonyflake(settings)
+	if r.generator == nil && c.MachineID == nil {
+		log.Warn("cannot derive sonyflake machine id from a private IPv4 address, using hostname hash instead")
+		settings.MachineID = hostnameMachineID
+		r.generator = sonyflake.NewSonyflake(settings)
+	}
+	if r.generator == nil {
+		return r, serr.Errorf("cannot create sonyflake with start time %s, check the start time and machine id", c.StartTime.Format(time.RFC3339))
+	}
+	return r, nil
+}
+
+// hostnameMachineID 主机名的 FNV 哈希的低 16 位，容器中主机名通常是唯一的容器 ID 或 Pod 名称
+func hostnameMachineID() (uint16, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return 0, serr.WithStack(err)
 	}
+	h := fnv.New32a()
+	h.Write([]byte(hostname))
+	return uint16(h.Sum32()), nil
 }
 
 func (x *SonyflakeIDGenerator) GenerateString() string {
-	id, err := x.generator.NextID()
+	id, err := x.GenerateUint64()
 	if err != nil {
 		log.Errorf("flake.NextID() failed with %s\n", err)
 	}
-	return fmt.Sprintf("%x", id)
+	return x.encoding.Encode(id)
+}
+
+func (x *SonyflakeIDGenerator) Generate() (string, error) {
+	id, err := x.GenerateUint64()
+	if err != nil {
+		return "", err
+	}
+	return x.encoding.Encode(id), nil
+}
+
+func (x *SonyflakeIDGenerator) GenerateUint64() (uint64, error) {
+	if x.generator == nil {
+		return 0, serr.New("sonyflake is not initialized")
+	}
+	id, err := x.generator.NextID()
+	return id, serr.WithStack(err)
+}
+
+// GenerateInt64 sonyflake ID 只有 63 位，可以直接保存到数据库的 BIGINT 列
+func (x *SonyflakeIDGenerator) GenerateInt64() (int64, error) {
+	id, err := x.GenerateUint64()
+	return int64(id), err
+}
+
+// Decompose 分解出 ID 的生成时间（精度 10 毫秒）、序列号和机器 ID
+func (x *SonyflakeIDGenerator) Decompose(id uint64) SonyflakeParts {
+	const maskSequence = 1<<sonyflake.BitLenSequence - 1
+	const maskMachineID = 1<<sonyflake.BitLenMachineID - 1
+
+	elapsed := int64(id >> (sonyflake.BitLenSequence + sonyflake.BitLenMachineID))
+	return SonyflakeParts{
+		ID:        id,
+		Time:      x.startTime.Add(time.Duration(elapsed) * 10 * time.Millisecond),
+		Sequence:  uint16(id >> sonyflake.BitLenMachineID & maskSequence),
+		MachineID: uint16(id & maskMachineID),
+	}
+}
+
+// Parse 按生成器的编码解码并分解 ID
+func (x *SonyflakeIDGenerator) Parse(s string) (SonyflakeParts, error) {
+	id, err := x.encoding.Decode(s)
+	if err != nil {
+		return SonyflakeParts{}, err
+	}
+	if id>>63 != 0 {
+		return SonyflakeParts{}, serr.Errorf("invalid sonyflake id '%s'", s)
+	}
+	return x.Decompose(id), nil
 }
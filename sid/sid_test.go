@@ -0,0 +1,161 @@
+package sid
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUUID(t *testing.T) {
+	v4, err := NewUUIDv4()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, v4.Version())
+	_, err = v4.Time()
+	assert.Error(t, err)
+
+	parsed, err := ParseUUID(strings.ToUpper(v4.String()))
+	assert.NoError(t, err)
+	assert.Equal(t, v4, parsed)
+
+	_, err = ParseUUID("0190b5c6-2a7e-7c3d-9f1e-8a2b3c4d5e6")
+	assert.Error(t, err)
+	_, err = ParseUUID("0190b5c6-2a7e-7c3d-9f1e-8a2b3c4d5e6z")
+	assert.Error(t, err)
+
+	start := time.Now().Truncate(time.Millisecond)
+	ids := make([]string, 1000)
+	for i := range ids {
+		id, err := NewUUIDv7()
+		assert.NoError(t, err)
+		assert.Equal(t, 7, id.Version())
+		assert.Equal(t, byte(0x80), id[8]&0xC0)
+		ids[i] = id.String()
+	}
+	// 同一毫秒内也是递增的
+	assert.True(t, sort.StringsAreSorted(ids))
+
+	parsed, err = ParseUUID(ids[0])
+	assert.NoError(t, err)
+	ts, err := parsed.Time()
+	assert.NoError(t, err)
+	assert.False(t, ts.Before(start))
+	assert.True(t, ts.Sub(start) < time.Second)
+}
+
+func TestULID(t *testing.T) {
+	start := time.Now().Truncate(time.Millisecond)
+	ids := make([]string, 1000)
+	for i := range ids {
+		id, err := NewULID()
+		assert.NoError(t, err)
+		ids[i] = id.String()
+		assert.Len(t, ids[i], 26)
+	}
+	assert.True(t, sort.StringsAreSorted(ids))
+
+	id, err := ParseULID(strings.ToLower(ids[0]))
+	assert.NoError(t, err)
+	assert.Equal(t, ids[0], id.String())
+	assert.False(t, id.Time().Before(start))
+	assert.True(t, id.Time().Sub(start) < time.Second)
+
+	id, err = ParseULID("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1469922850259), id.Time().UnixNano()/int64(time.Millisecond))
+	aliased, err := ParseULID("O1ARZ3NDEKTSV4RRFFQ69G5FAV")
+	assert.NoError(t, err)
+	assert.Equal(t, id, aliased)
+
+	_, err = ParseULID("81ARZ3NDEKTSV4RRFFQ69G5FAV")
+	assert.Error(t, err)
+	_, err = ParseULID("01ARZ3NDEKTSV4RRFFQ69G5FAU")
+	assert.Error(t, err)
+	_, err = ParseULID("01ARZ3NDEKTSV4RRFFQ69G5FA")
+	assert.Error(t, err)
+}
+
+func TestNanoID(t *testing.T) {
+	g := NewNanoIDGenerator(0, "")
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := g.GenerateString()
+		assert.NoError(t, ValidateNanoID(id))
+		assert.False(t, seen[id])
+		seen[id] = true
+	}
+
+	hex := NewNanoIDGenerator(8, "0123456789abcdef").(*NanoIDGenerator)
+	id := hex.GenerateString()
+	assert.Len(t, id, 8)
+	assert.NoError(t, hex.Validate(id))
+	assert.Error(t, hex.Validate("0123456g"))
+	assert.Error(t, hex.Validate("0123"))
+}
+
+func TestEncoding(t *testing.T) {
+	values := []uint64{0, 1, 61, 62, 1 << 32, 1<<63 - 1, 1<<64 - 1}
+	for _, encoding := range []IDEncoding{EncodingHex, EncodingDecimal, EncodingBase62, EncodingBase32} {
+		encoded := make([]string, len(values))
+		for i, v := range values {
+			encoded[i] = encoding.Encode(v)
+			decoded, err := encoding.Decode(encoded[i])
+			assert.NoError(t, err)
+			assert.Equal(t, v, decoded)
+		}
+		if encoding == EncodingBase62 || encoding == EncodingBase32 {
+			assert.True(t, sort.StringsAreSorted(encoded), encoded)
+		}
+	}
+
+	assert.Equal(t, "LygHa16AHYF", EncodeBase62(1<<64-1))
+	assert.Equal(t, "FZZZZZZZZZZZZ", EncodeBase32(1<<64-1))
+	v, err := DecodeBase32("fzzzzzzzzzzzz")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1<<64-1), v)
+
+	_, err = DecodeBase62("LygHa16AHYG")
+	assert.Error(t, err)
+	_, err = DecodeBase32("G000000000000")
+	assert.Error(t, err)
+	_, err = DecodeBase32("U")
+	assert.Error(t, err)
+}
+
+func TestSonyflakeIDGenerator(t *testing.T) {
+	// 没有私有 IPv4 地址时使用主机名的哈希
+	_, err := NewSonyflakeIDGenerator().(IIDGeneratorE).Generate()
+	assert.NoError(t, err)
+
+	g, err := NewSonyflakeIDGeneratorWithConfig(&SonyflakeConfig{
+		MachineID: func() (uint16, error) { return 42, nil },
+		Encoding:  EncodingBase62,
+	})
+	assert.NoError(t, err)
+
+	start := time.Now()
+	id, err := g.GenerateInt64()
+	assert.NoError(t, err)
+	assert.True(t, id > 0)
+	parts := g.Decompose(uint64(id))
+	assert.Equal(t, uint16(42), parts.MachineID)
+	assert.True(t, parts.Time.Sub(start) < 20*time.Millisecond && start.Sub(parts.Time) < 20*time.Millisecond)
+
+	ids := make([]string, 300)
+	for i := range ids {
+		ids[i], err = g.Generate()
+		assert.NoError(t, err)
+	}
+	assert.True(t, sort.StringsAreSorted(ids))
+	parsed, err := g.Parse(ids[0])
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(42), parsed.MachineID)
+
+	_, err = NewSonyflakeIDGeneratorWithConfig(&SonyflakeConfig{
+		MachineID: func() (uint16, error) { return 0, errors.New("no machine id") },
+	})
+	assert.Error(t, err)
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/syncfuture/go/sconfig"
+	"github.com/syncfuture/go/serr"
 
 	"github.com/kataras/golog"
 	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
@@ -28,6 +29,13 @@ var (
 	}
 )
 
+func init() {
+	// slog 引用了 serr，serr 不能反过来引用 slog，所以在这里把 slog 注册为 serr.Go 的错误处理
+	serr.SetPanicHandler(func(err error) {
+		Errorf("%+v", err)
+	})
+}
+
 type LogConfig struct {
 	Level       string
 	DetailLevel string
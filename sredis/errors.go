@@ -0,0 +1,31 @@
+package sredis
+
+import (
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/syncfuture/go/serr"
+)
+
+func init() {
+	serr.RegisterClassifier(ClassifyError)
+}
+
+// ClassifyError 判断 go-redis 返回的错误是否可以重试，ok 为 false 表示不是 go-redis 的错误
+func ClassifyError(err error) (retryable bool, ok bool) {
+	if serr.Is(err, redis.Nil) || serr.Is(err, redis.TxFailedErr) || serr.Is(err, redis.ErrClosed) {
+		return false, true
+	}
+
+	s := err.Error()
+	if s == "ERR max number of clients reached" || s == "redis: connection pool timeout" {
+		return true, true
+	}
+	for _, prefix := range []string{"LOADING ", "READONLY ", "CLUSTERDOWN ", "TRYAGAIN ", "MASTERDOWN "} {
+		if strings.HasPrefix(s, prefix) {
+			return true, true
+		}
+	}
+
+	return false, false
+}
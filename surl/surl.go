@@ -6,3 +6,21 @@ type IURLProvider interface {
 	RenderURL(url string) string
 	RenderURLCache(url string) string
 }
+
+// GetURIKey 返回 url 中第一个 {{URI 'key'}} 的 key，没有时返回空字符串
+func GetURIKey(url string) string {
+	matches := _regex.FindStringSubmatch(url)
+	if len(matches) > 1 {
+		return matches[1]
+	}
+	return ""
+}
+
+// ReplaceURIKey 用 baseURL 替换 url 中第一个 {{URI 'key'}}，没有时原样返回
+func ReplaceURIKey(url, baseURL string) string {
+	loc := _regex.FindStringIndex(url)
+	if loc == nil {
+		return url
+	}
+	return url[:loc[0]] + baseURL + url[loc[1]:]
+}
@@ -80,7 +80,12 @@ func LogErrorMsg(err error, mrPtr interface{}) bool {
 			panic("MsgCode must be a string field")
 		}
 
-		msgCodeField.SetString(err.Error())
+		// 有错误码时返回错误码，由前端或 serr.MessageCatalog 渲染为对应语言的消息
+		if code := serr.GetCode(err); code != "" {
+			msgCodeField.SetString(code)
+		} else {
+			msgCodeField.SetString(err.Error())
+		}
 
 		return true
 	}